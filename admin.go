@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// AuthorizationSchemeBearer is the `Authorization` header scheme for bearer tokens.
+	AuthorizationSchemeBearer = "Bearer"
+)
+
+// AdminRequired returns a middleware that requires the configured admin token
+// to be passed as a bearer token.
+func AdminRequired(cfg Config) web.Middleware {
+	return func(action web.Action) web.Action {
+		return func(r *web.Ctx) web.Result {
+			if cfg.AdminToken == "" {
+				return web.Text.NotAuthorized()
+			}
+			token, ok := BearerToken(r.Request)
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+				return web.Text.NotAuthorized()
+			}
+			return action(r)
+		}
+	}
+}
+
+// BearerToken returns the bearer token from the `Authorization` header of a request.
+func BearerToken(req *http.Request) (token string, ok bool) {
+	header := req.Header.Get("Authorization")
+	if len(header) <= len(AuthorizationSchemeBearer)+1 {
+		return
+	}
+	if !strings.EqualFold(header[:len(AuthorizationSchemeBearer)], AuthorizationSchemeBearer) || header[len(AuthorizationSchemeBearer)] != ' ' {
+		return
+	}
+	token = strings.TrimSpace(header[len(AuthorizationSchemeBearer)+1:])
+	ok = token != ""
+	return
+}
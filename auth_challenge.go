@@ -0,0 +1,404 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// HeaderWWWAuthenticate is the header authentication challenges are sent in.
+	HeaderWWWAuthenticate = "WWW-Authenticate"
+	// AuthRealm is the realm used in authentication challenges.
+	AuthRealm = "echo"
+	// DefaultAPIKeyHeader is the header `/auth/apikey` reads the key from.
+	DefaultAPIKeyHeader = "X-API-Key"
+	// DefaultDigestNonceTTL is how long a digest nonce is valid before it is reported stale.
+	DefaultDigestNonceTTL = 5 * time.Minute
+	// MaxDigestNonceTTL is the longest a digest nonce can be made valid for.
+	MaxDigestNonceTTL = time.Hour
+	// DigestNonceRetention is how long an expired nonce is remembered so it can be reported stale rather than unknown.
+	DigestNonceRetention = 10 * time.Minute
+	// MaxDigestNonces is the most nonces remembered; past it the oldest are forgotten,
+	// so anonymous challenges can't grow memory without limit.
+	MaxDigestNonces = 10000
+	// digestNonceSweepInterval is how often nonces that expired long enough ago are forgotten.
+	digestNonceSweepInterval = time.Minute
+)
+
+// Digest algorithms (RFC 7616 section 6.1).
+const (
+	DigestAlgorithmMD5        = "MD5"
+	DigestAlgorithmMD5Sess    = "MD5-sess"
+	DigestAlgorithmSHA256     = "SHA-256"
+	DigestAlgorithmSHA256Sess = "SHA-256-sess"
+)
+
+// Digest qop values.
+const (
+	DigestQOPAuth    = "auth"
+	DigestQOPAuthInt = "auth-int"
+)
+
+// AuthIdentity is the identity echoed back on successful authentication.
+type AuthIdentity struct {
+	Authenticated bool   `json:"authenticated"`
+	Scheme        string `json:"scheme"`
+	User          string `json:"user,omitempty"`
+	Algorithm     string `json:"algorithm,omitempty"`
+	QOP           string `json:"qop,omitempty"`
+	NonceCount    int64  `json:"nonceCount,omitempty"`
+	Header        string `json:"header,omitempty"`
+}
+
+// NewAuthChallenge returns a new auth challenge controller.
+func NewAuthChallenge(cfg Config) *AuthChallenge {
+	return &AuthChallenge{
+		Config: cfg,
+		nonces: map[string]*digestNonce{},
+	}
+}
+
+// AuthChallenge is a controller for endpoints that issue `WWW-Authenticate` challenges,
+// for testing clients that implement challenge / response authentication.
+type AuthChallenge struct {
+	Config Config
+
+	sync.Mutex
+	nonces map[string]*digestNonce
+	// order is the nonces in the order they were issued, oldest first; it may include forgotten nonces.
+	order     []string
+	lastSweep time.Time
+}
+
+// digestNonce is an issued digest nonce and the highest nonce count seen for it.
+type digestNonce struct {
+	Expires    time.Time
+	NonceCount int64
+}
+
+// Register implements web.Controller.
+func (ac *AuthChallenge) Register(app *web.App) {
+	app.GET("/auth/basic/:user/:pass", ac.basic)
+	app.GET("/auth/digest/:qop/:user/:pass", ac.digest)
+	app.POST("/auth/digest/:qop/:user/:pass", ac.digest)
+	app.GET("/auth/apikey", ac.apikey)
+}
+
+// basic requires http basic auth matching the user and password in the path.
+func (ac *AuthChallenge) basic(r *web.Ctx) web.Result {
+	expectedUser, _ := r.RouteParam("user")
+	expectedPass, _ := r.RouteParam("pass")
+
+	user, pass, ok := r.Request.BasicAuth()
+	if !ok || !constantTimeEquals(user, expectedUser) || !constantTimeEquals(pass, expectedPass) {
+		r.Response.Header().Set(HeaderWWWAuthenticate, fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, AuthRealm))
+		return web.JSON.Status(http.StatusUnauthorized, AuthIdentity{Scheme: "Basic"})
+	}
+	return web.JSON.Result(AuthIdentity{Authenticated: true, Scheme: "Basic", User: user})
+}
+
+// digest requires http digest auth (RFC 7616) matching the user and password in the path.
+//
+// `qop` is `auth` or `auth-int`; `?algorithm=` restricts the challenge to one algorithm,
+// otherwise both SHA-256 and MD5 are offered. `?nonceTTL=` sets how long issued nonces are valid
+// (up to an hour), which makes it practical to exercise `stale=true` handling.
+func (ac *AuthChallenge) digest(r *web.Ctx) web.Result {
+	qop, _ := r.RouteParam("qop")
+	if qop != DigestQOPAuth && qop != DigestQOPAuthInt {
+		return web.JSON.BadRequest(ex.New("invalid qop; must be `auth` or `auth-int`", ex.OptMessagef("qop: %s", qop)))
+	}
+	algorithms := []string{DigestAlgorithmSHA256, DigestAlgorithmMD5}
+	if algorithm := web.StringValue(r.QueryValue("algorithm")); algorithm != "" {
+		if digestHash(algorithm) == nil {
+			return web.JSON.BadRequest(ex.New("unsupported digest algorithm", ex.OptMessagef("algorithm: %s", algorithm)))
+		}
+		algorithms = []string{algorithm}
+	}
+	nonceTTL := DefaultDigestNonceTTL
+	if value := web.StringValue(r.QueryValue("nonceTTL")); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > MaxDigestNonceTTL {
+			return web.JSON.BadRequest(ex.New("invalid nonceTTL; must be a positive duration up to an hour", ex.OptMessagef("nonceTTL: %s", value)))
+		}
+		nonceTTL = parsed
+	}
+
+	expectedUser, _ := r.RouteParam("user")
+	expectedPass, _ := r.RouteParam("pass")
+
+	authorization := r.Request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+	params := ParseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		algorithm = DigestAlgorithmMD5
+	}
+	if !stringsContain(algorithms, algorithm) {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+	if params["realm"] != AuthRealm || params["qop"] != qop || params["uri"] != r.Request.RequestURI {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+	nonceCount, err := strconv.ParseInt(params["nc"], 16, 64)
+	if err != nil {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+
+	var body []byte
+	if qop == DigestQOPAuthInt {
+		if body, err = r.PostBody(); err != nil {
+			return web.JSON.BadRequest(err)
+		}
+	}
+
+	credentials := DigestCredentials{
+		Algorithm:   algorithm,
+		Username:    expectedUser,
+		Password:    expectedPass,
+		Nonce:       params["nonce"],
+		NonceCount:  params["nc"],
+		ClientNonce: params["cnonce"],
+		QOP:         qop,
+		Method:      r.Request.Method,
+		URI:         params["uri"],
+		Body:        body,
+	}
+	if !constantTimeEquals(params["username"], expectedUser) || !constantTimeEquals(params["response"], credentials.Response()) {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+
+	// the response is correct; check the nonce is one we issued, is still fresh, and the count was not replayed.
+	ac.Lock()
+	nonce, known := ac.nonces[credentials.Nonce]
+	var stale, replayed bool
+	if known {
+		stale = time.Now().UTC().After(nonce.Expires)
+		replayed = nonceCount <= nonce.NonceCount
+		if !stale && !replayed {
+			nonce.NonceCount = nonceCount
+		}
+	}
+	ac.Unlock()
+	if !known || replayed {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, false)
+	}
+	if stale {
+		return ac.digestChallenge(r, qop, algorithms, nonceTTL, true)
+	}
+
+	r.Response.Header().Set("Authentication-Info", fmt.Sprintf(`rspauth="%s", qop=%s, nc=%s, cnonce="%s"`, credentials.ResponseAuth(), qop, credentials.NonceCount, credentials.ClientNonce))
+	return web.JSON.Result(AuthIdentity{
+		Authenticated: true,
+		Scheme:        "Digest",
+		User:          expectedUser,
+		Algorithm:     algorithm,
+		QOP:           qop,
+		NonceCount:    nonceCount,
+	})
+}
+
+// apikey requires an api key in a header (`?header=`, default `X-API-Key`).
+// Keys are read from the config (`API_KEYS`); `?key=` adds an expected key for the request.
+func (ac *AuthChallenge) apikey(r *web.Ctx) web.Result {
+	header := web.StringValue(r.QueryValue("header"))
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	keys := ac.Config.APIKeysByName()
+	if key := web.StringValue(r.QueryValue("key")); key != "" {
+		keys["query"] = key
+	}
+
+	if value := r.Request.Header.Get(header); value != "" {
+		for name, key := range keys {
+			if constantTimeEquals(value, key) {
+				return web.JSON.Result(AuthIdentity{Authenticated: true, Scheme: "APIKey", User: name, Header: header})
+			}
+		}
+	}
+	r.Response.Header().Set(HeaderWWWAuthenticate, fmt.Sprintf(`APIKey realm="%s", header="%s"`, AuthRealm, header))
+	return web.JSON.Status(http.StatusUnauthorized, AuthIdentity{Scheme: "APIKey", Header: header})
+}
+
+// digestChallenge issues a new nonce and returns a 401 with a challenge per algorithm.
+func (ac *AuthChallenge) digestChallenge(r *web.Ctx, qop string, algorithms []string, nonceTTL time.Duration, stale bool) web.Result {
+	nonce := ac.newNonce(nonceTTL)
+	opaqueSum := md5.Sum([]byte(r.Request.URL.Path))
+	opaque := hex.EncodeToString(opaqueSum[:])
+	for _, algorithm := range algorithms {
+		challenge := fmt.Sprintf(`Digest realm="%s", qop="%s", algorithm=%s, nonce="%s", opaque="%s"`, AuthRealm, qop, algorithm, nonce, opaque)
+		if stale {
+			challenge += ", stale=true"
+		}
+		r.Response.Header().Add(HeaderWWWAuthenticate, challenge)
+	}
+	return web.JSON.Status(http.StatusUnauthorized, AuthIdentity{Scheme: "Digest", QOP: qop})
+}
+
+// newNonce issues a nonce, forgetting the oldest nonces if there are too many.
+func (ac *AuthChallenge) newNonce(ttl time.Duration) string {
+	buffer := make([]byte, 16)
+	rand.Read(buffer)
+	nonce := hex.EncodeToString(buffer)
+
+	now := time.Now().UTC()
+	ac.Lock()
+	defer ac.Unlock()
+	ac.sweep(now)
+	for len(ac.nonces) >= MaxDigestNonces && len(ac.order) > 0 {
+		delete(ac.nonces, ac.order[0])
+		ac.order = ac.order[1:]
+	}
+	ac.nonces[nonce] = &digestNonce{Expires: now.Add(ttl)}
+	ac.order = append(ac.order, nonce)
+	return nonce
+}
+
+// sweep forgets nonces that expired long enough ago, at most once a sweep interval.
+func (ac *AuthChallenge) sweep(now time.Time) {
+	if now.Sub(ac.lastSweep) < digestNonceSweepInterval {
+		return
+	}
+	ac.lastSweep = now
+	for key, value := range ac.nonces {
+		if now.Sub(value.Expires) > DigestNonceRetention {
+			delete(ac.nonces, key)
+		}
+	}
+	order := make([]string, 0, len(ac.nonces))
+	for _, key := range ac.order {
+		if _, ok := ac.nonces[key]; ok {
+			order = append(order, key)
+		}
+	}
+	ac.order = order
+}
+
+// DigestCredentials are the inputs to a digest response calculation.
+type DigestCredentials struct {
+	Algorithm   string
+	Username    string
+	Password    string
+	Nonce       string
+	NonceCount  string
+	ClientNonce string
+	QOP         string
+	Method      string
+	URI         string
+	Body        []byte
+}
+
+// Response returns the expected `response` for the credentials (RFC 7616 section 3.4.1).
+func (dc DigestCredentials) Response() string {
+	return dc.respond(dc.Method)
+}
+
+// ResponseAuth returns the `rspauth` for the `Authentication-Info` header (RFC 7616 section 3.5),
+// which is calculated like the response with an empty method.
+func (dc DigestCredentials) ResponseAuth() string {
+	return dc.respond("")
+}
+
+func (dc DigestCredentials) respond(method string) string {
+	h := func(values ...string) string {
+		hasher := digestHash(dc.Algorithm)
+		hasher.Write([]byte(strings.Join(values, ":")))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	ha1 := h(dc.Username, AuthRealm, dc.Password)
+	if strings.HasSuffix(dc.Algorithm, "-sess") {
+		ha1 = h(ha1, dc.Nonce, dc.ClientNonce)
+	}
+	ha2 := h(method, dc.URI)
+	if dc.QOP == DigestQOPAuthInt {
+		ha2 = h(method, dc.URI, h(string(dc.Body)))
+	}
+	return h(ha1, dc.Nonce, dc.NonceCount, dc.ClientNonce, dc.QOP, ha2)
+}
+
+// digestHash returns a new hash for a digest algorithm, or nil if it is unsupported.
+func digestHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case DigestAlgorithmMD5, DigestAlgorithmMD5Sess:
+		return md5.New()
+	case DigestAlgorithmSHA256, DigestAlgorithmSHA256Sess:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// ParseAuthParams parses a comma separated list of `key=value` or `key="quoted value"` auth params.
+func ParseAuthParams(value string) map[string]string {
+	output := map[string]string{}
+	for value = strings.TrimSpace(value); value != ""; value = strings.TrimSpace(value) {
+		equals := strings.IndexByte(value, '=')
+		if equals < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(value[:equals]))
+		value = strings.TrimSpace(value[equals+1:])
+
+		var param string
+		if strings.HasPrefix(value, `"`) {
+			var escaped bool
+			index := 1
+			for ; index < len(value); index++ {
+				if escaped {
+					param += string(value[index])
+					escaped = false
+					continue
+				}
+				if value[index] == '\\' {
+					escaped = true
+					continue
+				}
+				if value[index] == '"' {
+					break
+				}
+				param += string(value[index])
+			}
+			if index < len(value) {
+				index++
+			}
+			value = value[index:]
+		} else if comma := strings.IndexByte(value, ','); comma >= 0 {
+			param, value = strings.TrimSpace(value[:comma]), value[comma:]
+		} else {
+			param, value = strings.TrimSpace(value), ""
+		}
+		output[key] = param
+		value = strings.TrimPrefix(strings.TrimSpace(value), ",")
+	}
+	return output
+}
+
+func constantTimeEquals(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func stringsContain(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
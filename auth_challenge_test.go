@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+func TestDigestCredentialsResponse(t *testing.T) {
+	testCases := [...]struct {
+		Algorithm string
+		QOP       string
+		Expected  string
+	}{
+		{Algorithm: DigestAlgorithmMD5, QOP: DigestQOPAuth, Expected: "4aa8a18026f932d038ca2f4722969c0f"},
+		{Algorithm: DigestAlgorithmMD5Sess, QOP: DigestQOPAuth, Expected: "56196f32963367f3797f099f34a101f2"},
+		{Algorithm: DigestAlgorithmMD5, QOP: DigestQOPAuthInt, Expected: "8781c3ac33ef8ae5613806a01c748fe5"},
+		{Algorithm: DigestAlgorithmSHA256, QOP: DigestQOPAuth, Expected: "f1f09217c8eb59988f8566e020b32602271cd778db5a8acf007ace2bd79f9c41"},
+		{Algorithm: DigestAlgorithmSHA256Sess, QOP: DigestQOPAuthInt, Expected: "7fc9e0f929c0c92da3ac4f840b035ec4ecd83704f6ec479395193cf0cca2a3f1"},
+	}
+	for _, tc := range testCases {
+		credentials := DigestCredentials{
+			Algorithm:   tc.Algorithm,
+			Username:    "user",
+			Password:    "passwd",
+			Nonce:       "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+			NonceCount:  "00000001",
+			ClientNonce: "0a4f113b",
+			QOP:         tc.QOP,
+			Method:      http.MethodGet,
+			URI:         "/auth/digest/auth/user/passwd",
+			Body:        []byte("hello"),
+		}
+		if actual := credentials.Response(); actual != tc.Expected {
+			t.Errorf("%s %s: expected %s, got %s", tc.Algorithm, tc.QOP, tc.Expected, actual)
+		}
+	}
+}
+
+func TestDigestCredentialsResponseAuth(t *testing.T) {
+	credentials := DigestCredentials{
+		Algorithm:   DigestAlgorithmSHA256,
+		Username:    "user",
+		Password:    "passwd",
+		Nonce:       "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		NonceCount:  "00000001",
+		ClientNonce: "0a4f113b",
+		QOP:         DigestQOPAuth,
+		Method:      http.MethodGet,
+		URI:         "/auth/digest/auth/user/passwd",
+	}
+	if expected, actual := "84eda12389b9b2f3a3912eb83e25e98a6aa826a21a2be722d8bc4af54c858ddb", credentials.ResponseAuth(); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestDigestNonceTTL(t *testing.T) {
+	app := web.New()
+	app.Register(NewAuthChallenge(Config{}))
+
+	testCases := [...]struct {
+		NonceTTL string
+		Expected int
+	}{
+		{NonceTTL: "", Expected: http.StatusUnauthorized},
+		{NonceTTL: "1s", Expected: http.StatusUnauthorized},
+		{NonceTTL: "1h", Expected: http.StatusUnauthorized},
+		{NonceTTL: "61m", Expected: http.StatusBadRequest},
+		{NonceTTL: "87600h", Expected: http.StatusBadRequest},
+		{NonceTTL: "0s", Expected: http.StatusBadRequest},
+		{NonceTTL: "-1s", Expected: http.StatusBadRequest},
+		{NonceTTL: "soon", Expected: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/auth/digest/auth/user/passwd?nonceTTL="+tc.NonceTTL, nil))
+		if res.Code != tc.Expected {
+			t.Errorf("nonceTTL %q: expected %d, got %d: %s", tc.NonceTTL, tc.Expected, res.Code, strings.TrimSpace(res.Body.String()))
+		}
+	}
+}
+
+func TestDigestNonceLimit(t *testing.T) {
+	ac := NewAuthChallenge(Config{})
+	first := ac.newNonce(time.Minute)
+	var last string
+	for index := 0; index < MaxDigestNonces+10; index++ {
+		last = ac.newNonce(time.Minute)
+	}
+	if len(ac.nonces) != MaxDigestNonces {
+		t.Errorf("expected %d nonces, got %d", MaxDigestNonces, len(ac.nonces))
+	}
+	if _, ok := ac.nonces[first]; ok {
+		t.Error("expected the oldest nonce to be forgotten")
+	}
+	if _, ok := ac.nonces[last]; !ok {
+		t.Error("expected the newest nonce to be kept")
+	}
+}
+
+func TestDigestNonceSweep(t *testing.T) {
+	ac := NewAuthChallenge(Config{})
+	now := time.Now().UTC()
+	ac.nonces["expired"] = &digestNonce{Expires: now.Add(-DigestNonceRetention - time.Second)}
+	ac.nonces["stale"] = &digestNonce{Expires: now.Add(-time.Second)}
+	ac.order = []string{"expired", "stale"}
+
+	ac.sweep(now)
+	if _, ok := ac.nonces["expired"]; ok {
+		t.Error("expected the long expired nonce to be forgotten")
+	}
+	if _, ok := ac.nonces["stale"]; !ok {
+		t.Error("expected the recently expired nonce to be kept, to be reported stale")
+	}
+	if len(ac.order) != 1 || ac.order[0] != "stale" {
+		t.Errorf("expected the forgotten nonce to be dropped from the order, got %v", ac.order)
+	}
+}
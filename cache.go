@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/web"
+)
+
+// Cache headers.
+const (
+	HeaderETag              = "ETag"
+	HeaderLastModified      = "Last-Modified"
+	HeaderExpires           = "Expires"
+	HeaderIfMatch           = "If-Match"
+	HeaderIfNoneMatch       = "If-None-Match"
+	HeaderIfModifiedSince   = "If-Modified-Since"
+	HeaderIfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+const (
+	// DefaultETagMaxSize is the largest response buffered to compute an etag.
+	DefaultETagMaxSize = int(fileutil.Megabyte)
+)
+
+// ETags returns http middleware that adds etags to successful GET and HEAD responses
+// and answers their conditional requests (`If-Match`, `If-None-Match`, `If-Modified-Since`
+// and `If-Unmodified-Since`) with 304 or 412.
+//
+// Handlers can set their own `ETag` or `Last-Modified`; otherwise the response is buffered,
+// up to the max size, and the etag is the md5 of the body. Streamed (flushed) and oversized
+// responses get no computed etag, but are still checked against a `Last-Modified` set by the handler.
+func ETags(cfg Config) HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				next.ServeHTTP(w, req)
+				return
+			}
+			ew := &etagResponseWriter{ResponseWriter: w, config: cfg, request: req}
+			req = withPassthrough(req, ew)
+			defer ew.Close()
+			next.ServeHTTP(ew, req)
+		})
+	}
+}
+
+// etagResponseWriter buffers a successful response until its etag is known.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	config  Config
+	request *http.Request
+
+	statusCode  int
+	decided     bool
+	discard     bool
+	passthrough bool
+	buffer      []byte
+}
+
+func (ew *etagResponseWriter) WriteHeader(statusCode int) {
+	if ew.passthrough {
+		ew.statusCode = statusCode
+		ew.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if ew.decided || ew.statusCode != 0 {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		ew.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	ew.statusCode = statusCode
+	if statusCode != http.StatusOK || ew.Header().Get(HeaderETag) != "" {
+		ew.decide()
+	}
+}
+
+func (ew *etagResponseWriter) Write(contents []byte) (int, error) {
+	if ew.statusCode == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.discard {
+		return len(contents), nil
+	}
+	if ew.decided {
+		return ew.ResponseWriter.Write(contents)
+	}
+	ew.buffer = append(ew.buffer, contents...)
+	if len(ew.buffer) > ew.config.ETagMaxSizeOrDefault() {
+		if err := ew.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(contents), nil
+}
+
+// Flush gives up on computing an etag and flushes what's been written.
+func (ew *etagResponseWriter) Flush() {
+	if ew.statusCode == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.decided {
+		ew.decide()
+	}
+	if typed, ok := ew.ResponseWriter.(http.Flusher); ok {
+		typed.Flush()
+	}
+}
+
+// Close computes the etag of a complete buffered response and sends it.
+func (ew *etagResponseWriter) Close() error {
+	if ew.decided || ew.statusCode == 0 {
+		return nil
+	}
+	etag, err := fileutil.ETag(ew.buffer)
+	if err != nil {
+		return err
+	}
+	if ew.config.ETagWeak {
+		ew.Header().Set(HeaderETag, fmt.Sprintf("W/%q", etag))
+	} else {
+		ew.Header().Set(HeaderETag, strconv.Quote(etag))
+	}
+	return ew.decide()
+}
+
+// Passthrough implements passthroughWriter.
+func (ew *etagResponseWriter) Passthrough() {
+	ew.passthrough = true
+	ew.decided = true
+}
+
+// Hijack implements http.Hijacker for handlers that take over the connection.
+func (ew *etagResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := ew.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ex.New("response writer does not support hijacking")
+	}
+	ew.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the inner response writer for `http.ResponseController`.
+func (ew *etagResponseWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// decide evaluates the request's preconditions and writes the header and anything buffered.
+func (ew *etagResponseWriter) decide() error {
+	ew.decided = true
+	statusCode := ew.statusCode
+	if statusCode == http.StatusOK {
+		if precondition := CheckPreconditions(ew.request, ew.Header()); precondition != 0 {
+			statusCode = precondition
+			ew.discard = true
+			ew.buffer = nil
+			header := ew.Header()
+			header.Del(web.HeaderContentType)
+			header.Del(web.HeaderContentLength)
+			header.Del(web.HeaderContentEncoding)
+		}
+	}
+	ew.ResponseWriter.WriteHeader(statusCode)
+	buffer := ew.buffer
+	ew.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	_, err := ew.ResponseWriter.Write(buffer)
+	return err
+}
+
+// CheckPreconditions evaluates a GET or HEAD request's conditional headers against the
+// `ETag` and `Last-Modified` of a response, in the order RFC 9110 gives.
+// It returns 412 or 304 if the request's preconditions say so, or zero to send the response as is.
+func CheckPreconditions(req *http.Request, header http.Header) int {
+	etag := header.Get(HeaderETag)
+	lastModified, _ := http.ParseTime(header.Get(HeaderLastModified))
+
+	if ifMatch := req.Header.Get(HeaderIfMatch); ifMatch != "" {
+		if !ETagListMatches(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ifUnmodifiedSince, err := http.ParseTime(req.Header.Get(HeaderIfUnmodifiedSince)); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(ifUnmodifiedSince) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := req.Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		if ETagListMatches(ifNoneMatch, etag, false) {
+			return http.StatusNotModified
+		}
+	} else if ifModifiedSince, err := http.ParseTime(req.Header.Get(HeaderIfModifiedSince)); err == nil && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(ifModifiedSince) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// ETagListMatches returns if an etag is in a conditional header's list of etags (or it is `*`).
+// Strong comparison requires both tags be strong; weak comparison ignores the `W/` prefix.
+func ETagListMatches(list, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheSecondsDirectives are the `/cache/:seconds` query parameters for directives with a number of seconds.
+var cacheSecondsDirectives = [][2]string{
+	{"sMaxAge", "s-maxage"},
+	{"staleWhileRevalidate", "stale-while-revalidate"},
+	{"staleIfError", "stale-if-error"},
+}
+
+// cacheFlagDirectives are the `/cache/:seconds` query parameters for directives without a value.
+var cacheFlagDirectives = [][2]string{
+	{"noStore", "no-store"},
+	{"noCache", "no-cache"},
+	{"mustRevalidate", "must-revalidate"},
+	{"proxyRevalidate", "proxy-revalidate"},
+	{"noTransform", "no-transform"},
+	{"immutable", "immutable"},
+}
+
+// Cache is a controller for caching test endpoints.
+// Their conditional requests are answered by the `ETags` middleware.
+type Cache struct {
+	// LastModified is the `Last-Modified` of `/cache`, typically the app start.
+	LastModified time.Time
+}
+
+// Register implements web.Controller.
+func (c Cache) Register(app *web.App) {
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.HEAD} {
+		register("/cache", c.cache)
+		register("/cache/:seconds", c.cacheSeconds)
+		register("/etag/:etag", c.etag)
+	}
+}
+
+// CacheResponse is the body of the caching test endpoints.
+// It only reflects the response, so the computed etag is stable across requests.
+type CacheResponse struct {
+	Path         string `json:"path"`
+	CacheControl string `json:"cacheControl,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+}
+
+// cache is always revalidated and has a fixed `Last-Modified`.
+func (c Cache) cache(r *web.Ctx) web.Result {
+	header := r.Response.Header()
+	header.Set(web.HeaderCacheControl, "no-cache")
+	header.Set(HeaderLastModified, c.LastModified.UTC().Format(http.TimeFormat))
+	return Negotiated(r, FormatJSON, CacheResponse{
+		Path:         r.Request.URL.Path,
+		CacheControl: header.Get(web.HeaderCacheControl),
+		LastModified: header.Get(HeaderLastModified),
+	})
+}
+
+// cacheSeconds is fresh for a number of seconds, with the `Cache-Control` directives picked by query:
+//
+//	private, noStore, noCache, mustRevalidate, proxyRevalidate, noTransform, immutable (booleans)
+//	sMaxAge, staleWhileRevalidate, staleIfError (seconds)
+func (c Cache) cacheSeconds(r *web.Ctx) web.Result {
+	seconds, err := web.IntValue(r.RouteParam("seconds"))
+	if err != nil || seconds < 0 {
+		return web.JSON.BadRequest(ex.New("invalid seconds; must be a non-negative integer"))
+	}
+	query := r.Request.URL.Query()
+
+	directives := []string{"public"}
+	if private, _ := web.BoolValue(query.Get("private"), nil); private {
+		directives[0] = "private"
+	}
+	directives = append(directives, fmt.Sprintf("max-age=%d", seconds))
+	for _, directive := range cacheSecondsDirectives {
+		if value := query.Get(directive[0]); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return web.JSON.BadRequest(ex.New("invalid cache directive; must be a non-negative integer", ex.OptMessagef("directive: %s", directive[0])))
+			}
+			directives = append(directives, fmt.Sprintf("%s=%d", directive[1], parsed))
+		}
+	}
+	for _, directive := range cacheFlagDirectives {
+		if enabled, _ := web.BoolValue(query.Get(directive[0]), nil); enabled {
+			directives = append(directives, directive[1])
+		}
+	}
+
+	header := r.Response.Header()
+	header.Set(web.HeaderCacheControl, strings.Join(directives, ", "))
+	header.Set(HeaderExpires, time.Now().UTC().Add(time.Duration(seconds)*time.Second).Format(http.TimeFormat))
+	return Negotiated(r, FormatJSON, CacheResponse{
+		Path:         r.Request.URL.Path,
+		CacheControl: header.Get(web.HeaderCacheControl),
+	})
+}
+
+// etag responds with a given etag, quoted unless it already is.
+func (c Cache) etag(r *web.Ctx) web.Result {
+	etag, err := r.RouteParam("etag")
+	if err != nil {
+		return web.JSON.BadRequest(err)
+	}
+	if !strings.HasSuffix(etag, `"`) {
+		etag = strconv.Quote(etag)
+	}
+	r.Response.Header().Set(HeaderETag, etag)
+	return Negotiated(r, FormatJSON, CacheResponse{
+		Path: r.Request.URL.Path,
+		ETag: etag,
+	})
+}
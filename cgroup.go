@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+)
+
+const (
+	// DefaultCgroupRoot is where the cgroup filesystem is mounted.
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
+	// cgroupV1MemoryUnlimited is the threshold above which a cgroup v1 memory limit is treated as unset.
+	// The kernel reports "unlimited" as the largest page aligned int64.
+	cgroupV1MemoryUnlimited int64 = 1 << 62
+)
+
+// Cgroup is the resource limits and usage of the cgroup the process runs in.
+// Zero values for limits mean there is no limit.
+type Cgroup struct {
+	Version         int     `json:"version"`
+	CPUQuotaMicros  int64   `json:"cpuQuotaMicros,omitempty"`
+	CPUPeriodMicros int64   `json:"cpuPeriodMicros,omitempty"`
+	CPULimit        float64 `json:"cpuLimit,omitempty"`
+	MemoryLimit     int64   `json:"memoryLimit,omitempty"`
+	MemoryUsage     int64   `json:"memoryUsage,omitempty"`
+}
+
+// ReadCgroup reads the cgroup limits from a cgroup filesystem root, detecting v1 or v2.
+// Inside a container the cgroup namespace makes the root the container's own cgroup.
+func ReadCgroup(root string) (*Cgroup, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readCgroupV2(root)
+	}
+	if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		return readCgroupV1(root)
+	}
+	return nil, ex.New("no cgroup filesystem found", ex.OptMessagef("root: %s", root))
+}
+
+// readCgroupV2 reads limits from the unified hierarchy.
+func readCgroupV2(root string) (*Cgroup, error) {
+	cg := Cgroup{Version: 2}
+
+	// cpu.max is "$MAX $PERIOD" where $MAX may be "max".
+	cpuMax, err := readCgroupFile(root, "cpu.max")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, ex.New(err)
+	}
+	if fields := strings.Fields(cpuMax); len(fields) == 2 && fields[0] != "max" {
+		if cg.CPUQuotaMicros, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return nil, ex.New(err)
+		}
+		if cg.CPUPeriodMicros, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil, ex.New(err)
+		}
+	}
+
+	if cg.MemoryLimit, err = readCgroupInt64(root, "memory.max"); err != nil {
+		return nil, err
+	}
+	if cg.MemoryUsage, err = readCgroupInt64(root, "memory.current"); err != nil {
+		return nil, err
+	}
+	cg.CPULimit = cpuLimit(cg.CPUQuotaMicros, cg.CPUPeriodMicros)
+	return &cg, nil
+}
+
+// readCgroupV1 reads limits from the per controller hierarchies.
+func readCgroupV1(root string) (*Cgroup, error) {
+	cg := Cgroup{Version: 1}
+
+	var err error
+	if cg.CPUQuotaMicros, err = readCgroupInt64(root, "cpu/cpu.cfs_quota_us"); err != nil {
+		return nil, err
+	}
+	if cg.CPUPeriodMicros, err = readCgroupInt64(root, "cpu/cpu.cfs_period_us"); err != nil {
+		return nil, err
+	}
+	if cg.CPUQuotaMicros < 0 {
+		cg.CPUQuotaMicros = 0
+	}
+	if cg.MemoryLimit, err = readCgroupInt64(root, "memory/memory.limit_in_bytes"); err != nil {
+		return nil, err
+	}
+	if cg.MemoryLimit >= cgroupV1MemoryUnlimited {
+		cg.MemoryLimit = 0
+	}
+	if cg.MemoryUsage, err = readCgroupInt64(root, "memory/memory.usage_in_bytes"); err != nil {
+		return nil, err
+	}
+	cg.CPULimit = cpuLimit(cg.CPUQuotaMicros, cg.CPUPeriodMicros)
+	return &cg, nil
+}
+
+// cpuLimit returns the cpu limit in (fractional) cores.
+func cpuLimit(quota, period int64) float64 {
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+	return float64(quota) / float64(period)
+}
+
+// readCgroupFile reads a cgroup file as a trimmed string.
+// Errors are returned unwrapped so callers can check `os.IsNotExist`.
+func readCgroupFile(root, name string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// readCgroupInt64 reads a cgroup file as an integer.
+// Missing files and "max" read as zero, i.e. unlimited.
+func readCgroupInt64(root, name string) (int64, error) {
+	contents, err := readCgroupFile(root, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, ex.New(err)
+	}
+	if contents == "max" || contents == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(contents, 10, 64)
+	if err != nil {
+		return 0, ex.New(err, ex.OptMessagef("file: %s", name))
+	}
+	return value, nil
+}
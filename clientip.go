@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+// HeaderForwarded is the standard forwarding header (RFC 7239).
+const HeaderForwarded = "Forwarded"
+
+// Client ip sources; the header a hop was read from, or the connection's remote address.
+const (
+	ClientIPSourceForwarded     = "forwarded"
+	ClientIPSourceXForwardedFor = "x-forwarded-for"
+	ClientIPSourceXRealIP       = "x-real-ip"
+	ClientIPSourceRemoteAddr    = "remote-addr"
+)
+
+// TrustedProxyAliases are the named ranges that may be given as trusted proxies.
+var TrustedProxyAliases = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7", "127.0.0.0/8", "::1/128"},
+}
+
+// NewClientIPResolver returns a client ip resolver that trusts the configured proxies.
+func NewClientIPResolver(cfg Config) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, proxy := range cfg.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		cidrs, ok := TrustedProxyAliases[strings.ToLower(proxy)]
+		if !ok {
+			cidrs = []string{proxy}
+		}
+		for _, cidr := range cidrs {
+			network, err := parseCIDR(cidr)
+			if err != nil {
+				return nil, ex.New("invalid trusted proxy; must be a CIDR, an ip, `loopback` or `private`", ex.OptMessagef("proxy: %s", proxy), ex.OptInner(err))
+			}
+			resolver.TrustedProxies = append(resolver.TrustedProxies, network)
+		}
+	}
+	return resolver, nil
+}
+
+// parseCIDR parses a CIDR, or an ip as a single address network.
+func parseCIDR(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, ex.New("invalid ip", ex.OptMessagef("ip: %s", value))
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, ex.New(err)
+	}
+	return network, nil
+}
+
+// ClientIPResolver resolves the client ip of requests from their forwarding headers,
+// believing only what trusted proxies say.
+//
+// The hops a request took are read from `Forwarded` if it's present, `X-Forwarded-For` if not,
+// or `X-Real-IP` failing both, followed by the connection's remote address. Starting from the
+// remote address, hops are skipped while they're trusted proxies; the first hop that isn't is the client.
+// If the remote address isn't a trusted proxy the headers could say anything, so it's the client.
+type ClientIPResolver struct {
+	TrustedProxies []*net.IPNet
+}
+
+// ClientIP is the resolved client ip of a request.
+type ClientIP struct {
+	IP         string `json:"ip"`
+	RemoteAddr string `json:"remoteAddr"`
+	// Source is where the client ip came from.
+	Source string `json:"source"`
+	// Hops are the hops the request took, from the original client to the remote address.
+	Hops []ClientIPHop `json:"hops"`
+	// TrustedProxies are the trusted proxy networks.
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// ClientIPHop is a hop a request took.
+type ClientIPHop struct {
+	// Address is the hop as given; an ip, or for `Forwarded` possibly `unknown` or an obfuscated identifier.
+	Address string `json:"address"`
+	// IP is the hop's ip, if the address has one.
+	IP     string `json:"ip,omitempty"`
+	Source string `json:"source"`
+	// Trusted is if the hop is a trusted proxy, and so was believed about the hop before it.
+	Trusted bool `json:"trusted"`
+	// Client is if the hop is the resolved client.
+	Client bool `json:"client,omitempty"`
+
+	// By, Proto and Host are the rest of a `Forwarded` element.
+	By    string `json:"by,omitempty"`
+	Proto string `json:"proto,omitempty"`
+	Host  string `json:"host,omitempty"`
+}
+
+// Trusted returns if an ip is a trusted proxy.
+func (cr *ClientIPResolver) Trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range cr.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve resolves the client ip of a request.
+func (cr *ClientIPResolver) Resolve(req *http.Request) (result ClientIP) {
+	result.RemoteAddr = req.RemoteAddr
+	result.TrustedProxies = make([]string, 0, len(cr.TrustedProxies))
+	for _, network := range cr.TrustedProxies {
+		result.TrustedProxies = append(result.TrustedProxies, network.String())
+	}
+
+	hops := ForwardedHops(req.Header)
+	remote := ClientIPHop{Address: req.RemoteAddr, Source: ClientIPSourceRemoteAddr}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		remote.IP = host
+	} else {
+		remote.IP = req.RemoteAddr
+	}
+	hops = append(hops, remote)
+
+	client := len(hops) - 1
+	for index := len(hops) - 1; index >= 0; index-- {
+		hops[index].Trusted = cr.Trusted(net.ParseIP(hops[index].IP))
+		client = index
+		if !hops[index].Trusted {
+			break
+		}
+	}
+	// a client the proxy couldn't (or wouldn't) name is represented by the proxy.
+	for client < len(hops)-1 && net.ParseIP(hops[client].IP) == nil {
+		client++
+	}
+	hops[client].Client = true
+
+	result.IP = hops[client].IP
+	result.Source = hops[client].Source
+	result.Hops = hops
+	return
+}
+
+// ForwardedHops returns the hops in a request's forwarding headers, from the original client
+// to the proxy that connected to us; from `Forwarded`, or `X-Forwarded-For`, or `X-Real-IP`.
+func ForwardedHops(header http.Header) (hops []ClientIPHop) {
+	if values := header.Values(HeaderForwarded); len(values) > 0 {
+		return parseForwarded(values)
+	}
+	if values := header.Values(webutil.HeaderXForwardedFor); len(values) > 0 {
+		for _, value := range values {
+			for _, address := range strings.Split(value, ",") {
+				if address = strings.TrimSpace(address); address != "" {
+					hops = append(hops, ClientIPHop{Address: address, IP: forwardedNodeIP(address), Source: ClientIPSourceXForwardedFor})
+				}
+			}
+		}
+		return
+	}
+	if address := strings.TrimSpace(header.Get(webutil.HeaderXRealIP)); address != "" {
+		hops = append(hops, ClientIPHop{Address: address, IP: forwardedNodeIP(address), Source: ClientIPSourceXRealIP})
+	}
+	return
+}
+
+// parseForwarded parses `Forwarded` headers, e.g. `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8::1]:4711"`;
+// an element without a `for` is still a hop, with an empty address.
+func parseForwarded(values []string) (hops []ClientIPHop) {
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			hop := ClientIPHop{Source: ClientIPSourceForwarded}
+			for _, pair := range splitQuoted(element, ';') {
+				equals := strings.Index(pair, "=")
+				if equals < 0 {
+					continue
+				}
+				name, value := strings.ToLower(strings.TrimSpace(pair[:equals])), unquote(strings.TrimSpace(pair[equals+1:]))
+				switch name {
+				case "for":
+					hop.Address, hop.IP = value, forwardedNodeIP(value)
+				case "by":
+					hop.By = value
+				case "proto":
+					hop.Proto = value
+				case "host":
+					hop.Host = value
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return
+}
+
+// splitQuoted splits a string on a separator outside of double quotes.
+func splitQuoted(value string, separator byte) (parts []string) {
+	var quoted, escaped bool
+	var start int
+	for index := 0; index < len(value); index++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && value[index] == '\\':
+			escaped = true
+		case value[index] == '"':
+			quoted = !quoted
+		case !quoted && value[index] == separator:
+			parts = append(parts, value[start:index])
+			start = index + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// unquote removes the quotes (and escapes) from a quoted string, if it is one.
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	var output strings.Builder
+	for index := 1; index < len(value)-1; index++ {
+		if value[index] == '\\' && index+1 < len(value)-1 {
+			index++
+		}
+		output.WriteByte(value[index])
+	}
+	return output.String()
+}
+
+// forwardedNodeIP returns the ip of a forwarded node, e.g. `192.0.2.60`, `192.0.2.60:80`, `[2001:db8::1]:80`,
+// or a bare `2001:db8::1`; or empty for `unknown`, obfuscated identifiers and anything else that isn't an ip.
+func forwardedNodeIP(node string) string {
+	host := node
+	if strings.HasPrefix(host, "[") {
+		if end := strings.Index(host, "]"); end > 0 {
+			host = host[1:end]
+		}
+	} else if strings.Count(host, ":") == 1 {
+		host = host[:strings.Index(host, ":")]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// LogRequest returns the request as it should be logged; the logger reads the client ip
+// from the forwarding headers regardless of who sent them, so the copy has the resolved
+// client ip as its remote address and no forwarding headers.
+func (cr *ClientIPResolver) LogRequest(req *http.Request) *http.Request {
+	if req == nil {
+		return nil
+	}
+	client := cr.Resolve(req)
+	if len(client.Hops) == 1 {
+		return req
+	}
+	logged := req.Clone(req.Context())
+	for _, name := range []string{HeaderForwarded, webutil.HeaderXForwardedFor, webutil.HeaderXRealIP} {
+		logged.Header.Del(name)
+	}
+	logged.RemoteAddr = net.JoinHostPort(client.IP, "0")
+	return logged
+}
+
+// Register implements web.Controller.
+func (cr *ClientIPResolver) Register(app *web.App) {
+	app.GET("/ip", cr.ip)
+}
+
+// ip reports the resolved client ip and how it was resolved.
+func (cr *ClientIPResolver) ip(r *web.Ctx) web.Result {
+	return Negotiated(r, FormatJSON, cr.Resolve(r.Request))
+}
+
+// ClientIPLog is a log that reports the resolved client ip for http request and response events.
+type ClientIPLog struct {
+	logger.Log
+	Resolver *ClientIPResolver
+}
+
+// Trigger implements logger.Triggerable.
+func (cl ClientIPLog) Trigger(ctx context.Context, e logger.Event) {
+	switch typed := e.(type) {
+	case *logger.HTTPRequestEvent:
+		typed.Request = cl.Resolver.LogRequest(typed.Request)
+	case *logger.HTTPResponseEvent:
+		typed.Request = cl.Resolver.LogRequest(typed.Request)
+	}
+	cl.Log.Trigger(ctx, e)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	resolver, err := NewClientIPResolver(Config{TrustedProxies: []string{"loopback", "10.0.0.0/8", "192.0.2.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := [...]struct {
+		Name       string
+		RemoteAddr string
+		Headers    map[string]string
+		Expected   string
+		Source     string
+	}{
+		{Name: "no headers", RemoteAddr: "203.0.113.5:1234", Expected: "203.0.113.5", Source: ClientIPSourceRemoteAddr},
+		{Name: "untrusted remote", RemoteAddr: "203.0.113.5:1234", Headers: map[string]string{"X-Forwarded-For": "198.51.100.7"}, Expected: "203.0.113.5", Source: ClientIPSourceRemoteAddr},
+		{Name: "trusted remote", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"X-Forwarded-For": "198.51.100.7"}, Expected: "198.51.100.7", Source: ClientIPSourceXForwardedFor},
+		{Name: "trusted chain", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"X-Forwarded-For": "198.51.100.7, 10.1.2.3, 192.0.2.1"}, Expected: "198.51.100.7", Source: ClientIPSourceXForwardedFor},
+		{Name: "spoofed hop before untrusted", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7, 10.1.2.3"}, Expected: "198.51.100.7", Source: ClientIPSourceXForwardedFor},
+		{Name: "all trusted", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"X-Forwarded-For": "10.1.2.3"}, Expected: "10.1.2.3", Source: ClientIPSourceXForwardedFor},
+		{Name: "forwarded wins", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https`, "X-Forwarded-For": "198.51.100.7"}, Expected: "2001:db8::1", Source: ClientIPSourceForwarded},
+		{Name: "forwarded unknown", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"Forwarded": "for=unknown, for=10.1.2.3"}, Expected: "10.1.2.3", Source: ClientIPSourceForwarded},
+		{Name: "x-real-ip", RemoteAddr: "127.0.0.1:1234", Headers: map[string]string{"X-Real-IP": "198.51.100.7"}, Expected: "198.51.100.7", Source: ClientIPSourceXRealIP},
+		{Name: "ipv6 remote", RemoteAddr: "[::1]:1234", Headers: map[string]string{"X-Forwarded-For": "198.51.100.7"}, Expected: "198.51.100.7", Source: ClientIPSourceXForwardedFor},
+	}
+	for _, tc := range testCases {
+		req := &http.Request{RemoteAddr: tc.RemoteAddr, Header: http.Header{}}
+		for name, value := range tc.Headers {
+			req.Header.Set(name, value)
+		}
+		result := resolver.Resolve(req)
+		if result.IP != tc.Expected || result.Source != tc.Source {
+			t.Errorf("%s: expected %s from %s, got %s from %s", tc.Name, tc.Expected, tc.Source, result.IP, result.Source)
+		}
+		var clients int
+		for _, hop := range result.Hops {
+			if hop.Client {
+				clients++
+			}
+		}
+		if clients != 1 {
+			t.Errorf("%s: expected exactly one client hop, got %d", tc.Name, clients)
+		}
+	}
+}
+
+func TestNewClientIPResolverInvalid(t *testing.T) {
+	if _, err := NewClientIPResolver(Config{TrustedProxies: []string{"not-a-network"}}); err == nil {
+		t.Error("expected an invalid trusted proxy to be an error")
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	hops := parseForwarded([]string{
+		`for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`,
+		`For="_hidden";Host="example.com;x=1", for=198.51.100.7:80`,
+	})
+	expected := []ClientIPHop{
+		{Address: "192.0.2.60", IP: "192.0.2.60", Source: ClientIPSourceForwarded, By: "203.0.113.43", Proto: "http"},
+		{Address: "[2001:db8:cafe::17]:4711", IP: "2001:db8:cafe::17", Source: ClientIPSourceForwarded},
+		{Address: "_hidden", Source: ClientIPSourceForwarded, Host: "example.com;x=1"},
+		{Address: "198.51.100.7:80", IP: "198.51.100.7", Source: ClientIPSourceForwarded},
+	}
+	if !reflect.DeepEqual(hops, expected) {
+		t.Errorf("expected %+v, got %+v", expected, hops)
+	}
+}
+
+func TestForwardedNodeIP(t *testing.T) {
+	testCases := [...]struct {
+		Node     string
+		Expected string
+	}{
+		{Node: "192.0.2.60", Expected: "192.0.2.60"},
+		{Node: "192.0.2.60:80", Expected: "192.0.2.60"},
+		{Node: "[2001:db8::1]:80", Expected: "2001:db8::1"},
+		{Node: "[2001:db8::1]", Expected: "2001:db8::1"},
+		{Node: "2001:db8::1", Expected: "2001:db8::1"},
+		{Node: "unknown"},
+		{Node: "_obfuscated"},
+		{Node: ""},
+	}
+	for _, tc := range testCases {
+		if actual := forwardedNodeIP(tc.Node); actual != tc.Expected {
+			t.Errorf("%q: expected %q, got %q", tc.Node, tc.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// Content encodings we can compress with.
+// Note `deflate` is the zlib format (RFC 1950), not a raw deflate stream.
+const (
+	ContentEncodingGZIP    = "gzip"
+	ContentEncodingDeflate = "deflate"
+)
+
+const (
+	// DefaultCompressionMinSize is the smallest complete response we compress.
+	DefaultCompressionMinSize = 1024
+)
+
+// DefaultCompressionContentTypes are the content types compressed by default.
+var DefaultCompressionContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/yaml",
+	"application/x-yaml",
+	"image/svg+xml",
+}
+
+// compressionEncodings are the encodings we support, in order of preference when q-values tie.
+var compressionEncodings = []string{ContentEncodingGZIP, ContentEncodingDeflate}
+
+type acceptEncodingKey struct{}
+
+// Compress returns http middleware that compresses responses with the best encoding the client accepts.
+//
+// Responses are buffered up to the minimum size; a complete response smaller than that is sent as is.
+// A flush before the minimum size commits to compression, so streams are compressed and still flush promptly.
+// The request's `Accept-Encoding` is hidden from the app (which would otherwise gzip everything itself)
+// and put back by `RestoreAcceptEncoding` so handlers still see it.
+func Compress(cfg Config) HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			cw := &compressResponseWriter{ResponseWriter: w, config: cfg}
+			if acceptEncoding, ok := req.Header[web.HeaderAcceptEncoding]; ok {
+				cw.encoding = NegotiateEncoding(strings.Join(acceptEncoding, ","), compressionEncodings...)
+				req = req.WithContext(context.WithValue(req.Context(), acceptEncodingKey{}, acceptEncoding))
+				req.Header.Del(web.HeaderAcceptEncoding)
+			}
+			req = withPassthrough(req, cw)
+			cw.request = req
+			defer cw.Close()
+			next.ServeHTTP(cw, req)
+		})
+	}
+}
+
+// RestoreAcceptEncoding is web middleware that puts back the `Accept-Encoding` header `Compress` hid from the app.
+func RestoreAcceptEncoding(action web.Action) web.Action {
+	return func(r *web.Ctx) web.Result {
+		if acceptEncoding, ok := r.Request.Context().Value(acceptEncodingKey{}).([]string); ok {
+			r.Request.Header[web.HeaderAcceptEncoding] = acceptEncoding
+		}
+		return action(r)
+	}
+}
+
+// NegotiateEncoding returns the supported encoding with the highest q-value in an `Accept-Encoding` header,
+// preferring earlier supported encodings on ties, or an empty string if none are acceptable.
+func NegotiateEncoding(acceptEncoding string, supported ...string) string {
+	qualities := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, quality := parseQualityValue(part)
+		if coding == "" {
+			continue
+		}
+		if coding == "*" {
+			wildcard = quality
+			continue
+		}
+		qualities[coding] = quality
+	}
+
+	var best string
+	var bestQuality float64
+	for _, encoding := range supported {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// parseQualityValue parses a list element like `gzip;q=0.8` into its lowercased value and quality (default 1).
+func parseQualityValue(part string) (value string, quality float64) {
+	quality = 1
+	pieces := strings.Split(part, ";")
+	value = strings.ToLower(strings.TrimSpace(pieces[0]))
+	for _, param := range pieces[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(strings.ToLower(param), "q=") {
+			if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				quality = parsed
+			}
+		}
+	}
+	return
+}
+
+// compressWriter is the common interface of the gzip and zlib writers.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressResponseWriter buffers the start of a response until it can decide whether to compress it.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	config   Config
+	request  *http.Request
+	encoding string
+
+	statusCode  int
+	decided     bool
+	passthrough bool
+	buffer      []byte
+	compressor  compressWriter
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.passthrough {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if cw.decided {
+		return
+	}
+	// informational responses (e.g. 103 early hints) go straight through.
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.statusCode = statusCode
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || statusCode == http.StatusSwitchingProtocols {
+		cw.decide(true)
+	}
+}
+
+func (cw *compressResponseWriter) Write(contents []byte) (int, error) {
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, contents...)
+		if len(cw.buffer) >= cw.config.CompressionMinSizeOrDefault() {
+			if err := cw.decide(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(contents), nil
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Write(contents)
+	}
+	return cw.ResponseWriter.Write(contents)
+}
+
+// Flush commits to a decision and flushes through the compressor.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.compressor != nil {
+		cw.compressor.Flush()
+	}
+	if typed, ok := cw.ResponseWriter.(http.Flusher); ok {
+		typed.Flush()
+	}
+}
+
+// Close finishes the response, sending anything still buffered.
+func (cw *compressResponseWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			return err
+		}
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Close()
+	}
+	return nil
+}
+
+// Passthrough implements passthroughWriter.
+func (cw *compressResponseWriter) Passthrough() {
+	cw.passthrough = true
+	cw.decided = true
+}
+
+// Hijack implements http.Hijacker for handlers that take over the connection.
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ex.New("response writer does not support hijacking")
+	}
+	cw.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the inner response writer for `http.ResponseController`.
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes the header, compressing the rest of the response if it is eligible.
+// `complete` means the buffer holds the whole response.
+func (cw *compressResponseWriter) decide(complete bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if cw.statusCode == 0 {
+		cw.statusCode = http.StatusOK
+	}
+	// the app marks uncompressed responses `identity`; that's the default, so it's dropped.
+	if header.Get(web.HeaderContentEncoding) == web.ContentEncodingIdentity {
+		header.Del(web.HeaderContentEncoding)
+	}
+	// sniff the content type now, as net/http would on the first write, so it can be checked.
+	if header.Get(web.HeaderContentType) == "" && header.Get(web.HeaderContentEncoding) == "" && len(cw.buffer) > 0 {
+		header.Set(web.HeaderContentType, http.DetectContentType(cw.buffer))
+	}
+
+	if cw.compressible() {
+		addVary(header, web.HeaderAcceptEncoding)
+		if cw.encoding != "" && (!complete || len(cw.buffer) >= cw.config.CompressionMinSizeOrDefault()) {
+			header.Set(web.HeaderContentEncoding, cw.encoding)
+			header.Del(web.HeaderContentLength)
+			if etag := header.Get(HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set(HeaderETag, "W/"+etag)
+			}
+			if cw.encoding == ContentEncodingGZIP {
+				cw.compressor = gzip.NewWriter(cw.ResponseWriter)
+			} else {
+				cw.compressor = zlib.NewWriter(cw.ResponseWriter)
+			}
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	buffer := cw.buffer
+	cw.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buffer)
+	return err
+}
+
+// compressible returns if the response could be compressed, whatever the client accepts.
+func (cw *compressResponseWriter) compressible() bool {
+	header := cw.Header()
+	if cw.request.Method == http.MethodHead {
+		return false
+	}
+	if cw.statusCode < http.StatusOK || cw.statusCode == http.StatusNoContent || cw.statusCode == http.StatusNotModified || cw.statusCode == http.StatusPartialContent {
+		return false
+	}
+	if header.Get(web.HeaderContentEncoding) != "" {
+		return false // already encoded by the handler.
+	}
+	if strings.Contains(strings.ToLower(header.Get(web.HeaderCacheControl)), "no-transform") {
+		return false
+	}
+	return cw.config.CompressesContentType(header.Get(web.HeaderContentType))
+}
+
+// addVary adds a field to the `Vary` header if it isn't already there.
+func addVary(header http.Header, field string) {
+	for _, value := range header.Values(web.HeaderVary) {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, field) {
+				return
+			}
+		}
+	}
+	header.Add(web.HeaderVary, field)
+}
+
+// Compression is a controller for endpoints that always return compressed responses.
+// They are only registered with the `Compress` middleware, as the app would otherwise gzip them again.
+type Compression struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (c Compression) Register(app *web.App) {
+	if c.Config.CompressionDisabled {
+		return
+	}
+	app.GET("/gzip", c.gzip)
+	app.GET("/deflate", c.deflate)
+}
+
+// CompressedResponse is the body of the compression test endpoints.
+type CompressedResponse struct {
+	Encoding string      `json:"encoding"`
+	Method   string      `json:"method"`
+	Headers  http.Header `json:"headers"`
+}
+
+func (c Compression) gzip(r *web.Ctx) web.Result {
+	return &CompressedResult{Encoding: ContentEncodingGZIP, Response: c.response(r, ContentEncodingGZIP)}
+}
+
+func (c Compression) deflate(r *web.Ctx) web.Result {
+	return &CompressedResult{Encoding: ContentEncodingDeflate, Response: c.response(r, ContentEncodingDeflate)}
+}
+
+func (c Compression) response(r *web.Ctx, encoding string) CompressedResponse {
+	return CompressedResponse{
+		Encoding: encoding,
+		Method:   r.Request.Method,
+		Headers:  r.Request.Header,
+	}
+}
+
+// CompressedResult is a json result compressed regardless of `Accept-Encoding`.
+type CompressedResult struct {
+	Encoding string
+	Response interface{}
+}
+
+// Render writes the result to the response.
+func (cr *CompressedResult) Render(ctx *web.Ctx) error {
+	var writer io.WriteCloser
+	if cr.Encoding == ContentEncodingDeflate {
+		writer = zlib.NewWriter(ctx.Response)
+	} else {
+		writer = gzip.NewWriter(ctx.Response)
+	}
+	ctx.Response.Header().Set(web.HeaderContentType, web.ContentTypeApplicationJSON)
+	ctx.Response.Header().Set(web.HeaderContentEncoding, cr.Encoding)
+	ctx.Response.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(writer).Encode(cr.Response); err != nil {
+		return ex.New(err)
+	}
+	if err := writer.Close(); err != nil {
+		return ex.New(err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/env"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// DefaultMemoryLimitRatio is the default fraction of the cgroup memory limit used as the GC memory limit.
+	// The remainder is headroom for non-heap memory the soft limit does not account for.
+	DefaultMemoryLimitRatio = 0.9
+	// DefaultAuthManagerMode is the default session mode.
+	DefaultAuthManagerMode = web.AuthManagerModeLocal
+)
+
+// Config is the echo specific configuration.
+// The web server itself is configured separately through `web.Config`.
+type Config struct {
+	// AdminToken is the bearer token required by admin routes.
+	// If it is unset, admin routes are disabled.
+	AdminToken string `json:"adminToken,omitempty" yaml:"adminToken,omitempty" env:"ADMIN_TOKEN"`
+
+	// DebugEnabled enables the (admin only) pprof and diagnostics routes under `/debug/`.
+	DebugEnabled bool `json:"debugEnabled,omitempty" yaml:"debugEnabled,omitempty" env:"DEBUG_ENABLED"`
+
+	// JWTHMACSecrets are the shared secrets `/auth/jwt` verifies HS* tokens against.
+	JWTHMACSecrets []string `json:"jwtHMACSecrets,omitempty" yaml:"jwtHMACSecrets,omitempty" env:"JWT_HMAC_SECRETS,csv"`
+	// JWTPublicKeyFiles are paths to PEM encoded RSA or ECDSA public keys (or certificates) `/auth/jwt` verifies against.
+	JWTPublicKeyFiles []string `json:"jwtPublicKeyFiles,omitempty" yaml:"jwtPublicKeyFiles,omitempty" env:"JWT_PUBLIC_KEY_FILES,csv"`
+	// JWKSFile is the path to a local JWKS `/auth/jwt` verifies against.
+	JWKSFile string `json:"jwksFile,omitempty" yaml:"jwksFile,omitempty" env:"JWKS_FILE"`
+	// OIDCConfigFile is the path to the yaml config for the mock OpenID Connect provider.
+	// The provider routes are only registered if it is set.
+	OIDCConfigFile string `json:"oidcConfigFile,omitempty" yaml:"oidcConfigFile,omitempty" env:"OIDC_CONFIG_FILE"`
+	// AuthManagerMode is the session mode for `/session/*`; `local` (the default) or `jwt`.
+	// The web config reads the same setting but not from the environment.
+	AuthManagerMode string `json:"authManagerMode,omitempty" yaml:"authManagerMode,omitempty" env:"AUTH_MANAGER_MODE"`
+
+	// APIKeys are the keys `/auth/apikey` accepts, each as `name:key` or a bare key.
+	APIKeys []string `json:"apiKeys,omitempty" yaml:"apiKeys,omitempty" env:"API_KEYS,csv"`
+
+	// RedirectAllowedHosts are the hosts `/redirect-to` will redirect to, exactly or as `*.example.com` wildcards.
+	// Local paths and the request's own host are always allowed.
+	RedirectAllowedHosts []string `json:"redirectAllowedHosts,omitempty" yaml:"redirectAllowedHosts,omitempty" env:"REDIRECT_ALLOWED_HOSTS,csv"`
+
+	// CompressionDisabled disables the response compression middleware,
+	// leaving the app's own gzip-if-accepted behavior.
+	CompressionDisabled bool `json:"compressionDisabled,omitempty" yaml:"compressionDisabled,omitempty" env:"COMPRESSION_DISABLED"`
+	// CompressionMinSize is the smallest complete response, in bytes, that is compressed.
+	CompressionMinSize int `json:"compressionMinSize,omitempty" yaml:"compressionMinSize,omitempty" env:"COMPRESSION_MIN_SIZE"`
+	// CompressionContentTypes are the media types that are compressed, exactly or as `type/*` wildcards.
+	CompressionContentTypes []string `json:"compressionContentTypes,omitempty" yaml:"compressionContentTypes,omitempty" env:"COMPRESSION_CONTENT_TYPES,csv"`
+	// RequestMaxDecompressedSize is the largest a compressed request body may decode to, in bytes.
+	RequestMaxDecompressedSize int64 `json:"requestMaxDecompressedSize,omitempty" yaml:"requestMaxDecompressedSize,omitempty" env:"REQUEST_MAX_DECOMPRESSED_SIZE"`
+
+	// ETagsDisabled disables the middleware that adds etags to responses and answers conditional requests.
+	ETagsDisabled bool `json:"etagsDisabled,omitempty" yaml:"etagsDisabled,omitempty" env:"ETAGS_DISABLED"`
+	// ETagWeak marks computed etags as weak (`W/"..."`).
+	ETagWeak bool `json:"etagWeak,omitempty" yaml:"etagWeak,omitempty" env:"ETAG_WEAK"`
+	// ETagMaxSize is the largest response, in bytes, buffered to compute an etag.
+	ETagMaxSize int `json:"etagMaxSize,omitempty" yaml:"etagMaxSize,omitempty" env:"ETAG_MAX_SIZE"`
+
+	// StaticDir is a directory of files to serve under `/static/`.
+	// Nothing is served if it is unset.
+	StaticDir string `json:"staticDir,omitempty" yaml:"staticDir,omitempty" env:"STATIC_DIR"`
+	// StaticCached caches static files in memory the first time they are served.
+	StaticCached bool `json:"staticCached,omitempty" yaml:"staticCached,omitempty" env:"STATIC_CACHED"`
+
+	// UploadDir is where `/upload` streams files to; the system temp directory by default.
+	UploadDir string `json:"uploadDir,omitempty" yaml:"uploadDir,omitempty" env:"UPLOAD_DIR"`
+	// UploadKeepFiles keeps uploaded files instead of removing them once they're hashed.
+	UploadKeepFiles bool `json:"uploadKeepFiles,omitempty" yaml:"uploadKeepFiles,omitempty" env:"UPLOAD_KEEP_FILES"`
+	// UploadMaxFileSize is the largest single uploaded file, in bytes.
+	UploadMaxFileSize int64 `json:"uploadMaxFileSize,omitempty" yaml:"uploadMaxFileSize,omitempty" env:"UPLOAD_MAX_FILE_SIZE"`
+	// UploadMaxTotalSize is the largest total size of an upload's files, in bytes.
+	UploadMaxTotalSize int64 `json:"uploadMaxTotalSize,omitempty" yaml:"uploadMaxTotalSize,omitempty" env:"UPLOAD_MAX_TOTAL_SIZE"`
+
+	// RawMaxSize is the largest request, in bytes, `/raw` returns.
+	RawMaxSize int64 `json:"rawMaxSize,omitempty" yaml:"rawMaxSize,omitempty" env:"RAW_MAX_SIZE"`
+
+	// CORSAllowedOrigins are the origins allowed cross origin requests; exactly, with `*` wildcards
+	// (e.g. `https://*.example.com`, or `*` for any) or as `~` prefixed regular expressions.
+	// CORS headers are only sent if it is set.
+	CORSAllowedOrigins []string `json:"corsAllowedOrigins,omitempty" yaml:"corsAllowedOrigins,omitempty" env:"CORS_ALLOWED_ORIGINS,csv"`
+	// CORSAllowedMethods are the methods allowed cross origin.
+	CORSAllowedMethods []string `json:"corsAllowedMethods,omitempty" yaml:"corsAllowedMethods,omitempty" env:"CORS_ALLOWED_METHODS,csv"`
+	// CORSAllowedHeaders are the request headers allowed cross origin, or `*`.
+	// If it is unset the headers a preflight asks for are allowed.
+	CORSAllowedHeaders []string `json:"corsAllowedHeaders,omitempty" yaml:"corsAllowedHeaders,omitempty" env:"CORS_ALLOWED_HEADERS,csv"`
+	// CORSExposedHeaders are the response headers exposed to cross origin callers.
+	CORSExposedHeaders []string `json:"corsExposedHeaders,omitempty" yaml:"corsExposedHeaders,omitempty" env:"CORS_EXPOSED_HEADERS,csv"`
+	// CORSAllowCredentials allows cross origin requests with credentials (cookies and authorization).
+	CORSAllowCredentials bool `json:"corsAllowCredentials,omitempty" yaml:"corsAllowCredentials,omitempty" env:"CORS_ALLOW_CREDENTIALS"`
+	// CORSMaxAge is how long browsers may cache preflight results.
+	CORSMaxAge time.Duration `json:"corsMaxAge,omitempty" yaml:"corsMaxAge,omitempty" env:"CORS_MAX_AGE"`
+
+	// RateLimit is how many requests each key may make per rate limit window.
+	// Requests are only limited if it is set.
+	RateLimit int `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty" env:"RATE_LIMIT"`
+	// RateLimitWindow is the rate limit window.
+	RateLimitWindow time.Duration `json:"rateLimitWindow,omitempty" yaml:"rateLimitWindow,omitempty" env:"RATE_LIMIT_WINDOW"`
+	// RateLimitBurst is how many requests a key may make at once with the token bucket algorithm; the limit by default.
+	RateLimitBurst int `json:"rateLimitBurst,omitempty" yaml:"rateLimitBurst,omitempty" env:"RATE_LIMIT_BURST"`
+	// RateLimitAlgorithm is `token-bucket` (the default) or `sliding-window`.
+	RateLimitAlgorithm string `json:"rateLimitAlgorithm,omitempty" yaml:"rateLimitAlgorithm,omitempty" env:"RATE_LIMIT_ALGORITHM"`
+	// RateLimitKey is what requests are limited by; `ip` (the default), `route`, `jwt` (the verified subject)
+	// or `header:<name>`, or a combination like `ip+route`.
+	RateLimitKey string `json:"rateLimitKey,omitempty" yaml:"rateLimitKey,omitempty" env:"RATE_LIMIT_KEY"`
+	// RateLimitExemptPaths are the request paths that aren't limited, exactly or as `/prefix/*` wildcards;
+	// the health check path (`/`) by default, so probes aren't refused.
+	RateLimitExemptPaths []string `json:"rateLimitExemptPaths,omitempty" yaml:"rateLimitExemptPaths,omitempty" env:"RATE_LIMIT_EXEMPT_PATHS,csv"`
+
+	// TrustedProxies are the proxies whose forwarding headers are believed, as CIDRs or ips,
+	// or `loopback` or `private` for those ranges. Without any the client ip is the remote address.
+	TrustedProxies []string `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty" env:"TRUSTED_PROXIES,csv"`
+
+	// ConnMaxRequests is how many requests a connection serves before it's closed; unlimited by default.
+	ConnMaxRequests int `json:"connMaxRequests,omitempty" yaml:"connMaxRequests,omitempty" env:"CONN_MAX_REQUESTS"`
+	// ConnMaxAge is how long a connection is kept before it's closed, after its current request; forever by default.
+	ConnMaxAge time.Duration `json:"connMaxAge,omitempty" yaml:"connMaxAge,omitempty" env:"CONN_MAX_AGE"`
+
+	// ProxyProtocol is the PROXY protocol mode; `optional` reads a header if one is sent,
+	// `strict` requires one. It is disabled by default.
+	ProxyProtocol string `json:"proxyProtocol,omitempty" yaml:"proxyProtocol,omitempty" env:"PROXY_PROTOCOL"`
+	// ProxyProtocolTrustedSources are the sources allowed to send PROXY protocol headers, as CIDRs or ips,
+	// or `loopback` or `private`; they're required if the mode is set, since a header sets the client's address
+	// (and so its rate limit key and whether its forwarding headers are believed). In strict mode other sources are refused.
+	ProxyProtocolTrustedSources []string `json:"proxyProtocolTrustedSources,omitempty" yaml:"proxyProtocolTrustedSources,omitempty" env:"PROXY_PROTOCOL_TRUSTED_SOURCES,csv"`
+	// ProxyProtocolHeaderTimeout is how long a connection has to send its PROXY protocol header.
+	ProxyProtocolHeaderTimeout time.Duration `json:"proxyProtocolHeaderTimeout,omitempty" yaml:"proxyProtocolHeaderTimeout,omitempty" env:"PROXY_PROTOCOL_HEADER_TIMEOUT"`
+
+	// AutoMaxProcsDisabled disables deriving `GOMAXPROCS` from the cgroup cpu quota.
+	AutoMaxProcsDisabled bool `json:"autoMaxProcsDisabled,omitempty" yaml:"autoMaxProcsDisabled,omitempty" env:"AUTO_MAXPROCS_DISABLED"`
+	// AutoMemoryLimitDisabled disables deriving the GC memory limit from the cgroup memory limit.
+	AutoMemoryLimitDisabled bool `json:"autoMemoryLimitDisabled,omitempty" yaml:"autoMemoryLimitDisabled,omitempty" env:"AUTO_MEMORY_LIMIT_DISABLED"`
+	// MemoryLimitRatio is the fraction of the cgroup memory limit to use as the GC memory limit.
+	MemoryLimitRatio float64 `json:"memoryLimitRatio,omitempty" yaml:"memoryLimitRatio,omitempty" env:"MEMORY_LIMIT_RATIO"`
+}
+
+// MemoryLimitRatioOrDefault returns the memory limit ratio or a default.
+func (c Config) MemoryLimitRatioOrDefault() float64 {
+	if c.MemoryLimitRatio > 0 && c.MemoryLimitRatio <= 1 {
+		return c.MemoryLimitRatio
+	}
+	return DefaultMemoryLimitRatio
+}
+
+// AuthManagerModeOrDefault returns the auth manager mode or a default.
+func (c Config) AuthManagerModeOrDefault() web.AuthManagerMode {
+	if c.AuthManagerMode != "" {
+		return web.AuthManagerMode(c.AuthManagerMode)
+	}
+	return DefaultAuthManagerMode
+}
+
+// APIKeysByName returns the configured api keys by name.
+// Bare keys are named by their index, e.g. `key[0]`.
+func (c Config) APIKeysByName() map[string]string {
+	output := map[string]string{}
+	for index, value := range c.APIKeys {
+		if value == "" {
+			continue
+		}
+		if parts := strings.SplitN(value, ":", 2); len(parts) == 2 {
+			output[parts[0]] = parts[1]
+			continue
+		}
+		output[fmt.Sprintf("key[%d]", index)] = value
+	}
+	return output
+}
+
+// AllowsRedirectHost returns if `/redirect-to` may redirect to a host.
+func (c Config) AllowsRedirectHost(host string) bool {
+	for _, pattern := range c.RedirectAllowedHosts {
+		if pattern != "" && hostMatches(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompressionMinSizeOrDefault returns the compression minimum size or a default.
+func (c Config) CompressionMinSizeOrDefault() int {
+	if c.CompressionMinSize > 0 {
+		return c.CompressionMinSize
+	}
+	return DefaultCompressionMinSize
+}
+
+// CompressionContentTypesOrDefault returns the compressed content types or a default.
+func (c Config) CompressionContentTypesOrDefault() []string {
+	if len(c.CompressionContentTypes) > 0 {
+		return c.CompressionContentTypes
+	}
+	return DefaultCompressionContentTypes
+}
+
+// RequestMaxDecompressedSizeOrDefault returns the request decompressed size limit or a default.
+func (c Config) RequestMaxDecompressedSizeOrDefault() int64 {
+	if c.RequestMaxDecompressedSize > 0 {
+		return c.RequestMaxDecompressedSize
+	}
+	return DefaultRequestMaxDecompressedSize
+}
+
+// ETagMaxSizeOrDefault returns the etag buffer size limit or a default.
+func (c Config) ETagMaxSizeOrDefault() int {
+	if c.ETagMaxSize > 0 {
+		return c.ETagMaxSize
+	}
+	return DefaultETagMaxSize
+}
+
+// UploadDirOrDefault returns the upload directory or a default.
+func (c Config) UploadDirOrDefault() string {
+	if c.UploadDir != "" {
+		return c.UploadDir
+	}
+	return os.TempDir()
+}
+
+// UploadMaxFileSizeOrDefault returns the upload file size limit or a default.
+func (c Config) UploadMaxFileSizeOrDefault() int64 {
+	if c.UploadMaxFileSize > 0 {
+		return c.UploadMaxFileSize
+	}
+	return DefaultUploadMaxFileSize
+}
+
+// UploadMaxTotalSizeOrDefault returns the upload total size limit or a default.
+func (c Config) UploadMaxTotalSizeOrDefault() int64 {
+	if c.UploadMaxTotalSize > 0 {
+		return c.UploadMaxTotalSize
+	}
+	return DefaultUploadMaxTotalSize
+}
+
+// CORSAllowedMethodsOrDefault returns the methods allowed cross origin or a default.
+func (c Config) CORSAllowedMethodsOrDefault() []string {
+	if len(c.CORSAllowedMethods) > 0 {
+		return c.CORSAllowedMethods
+	}
+	return DefaultCORSAllowedMethods
+}
+
+// RateLimitWindowOrDefault returns the rate limit window or a default.
+func (c Config) RateLimitWindowOrDefault() time.Duration {
+	if c.RateLimitWindow > 0 {
+		return c.RateLimitWindow
+	}
+	return DefaultRateLimitWindow
+}
+
+// RateLimitBurstOrDefault returns the rate limit burst or the limit.
+func (c Config) RateLimitBurstOrDefault() int {
+	if c.RateLimitBurst > 0 {
+		return c.RateLimitBurst
+	}
+	return c.RateLimit
+}
+
+// RateLimitAlgorithmOrDefault returns the rate limit algorithm or a default.
+func (c Config) RateLimitAlgorithmOrDefault() string {
+	if c.RateLimitAlgorithm != "" {
+		return c.RateLimitAlgorithm
+	}
+	return DefaultRateLimitAlgorithm
+}
+
+// RateLimitKeyOrDefault returns the rate limit key or a default.
+func (c Config) RateLimitKeyOrDefault() string {
+	if c.RateLimitKey != "" {
+		return c.RateLimitKey
+	}
+	return DefaultRateLimitKey
+}
+
+// RateLimitExemptPathsOrDefault returns the paths that aren't rate limited or a default.
+func (c Config) RateLimitExemptPathsOrDefault() []string {
+	if len(c.RateLimitExemptPaths) > 0 {
+		return c.RateLimitExemptPaths
+	}
+	return DefaultRateLimitExemptPaths
+}
+
+// RawMaxSizeOrDefault returns the largest request `/raw` returns or a default.
+func (c Config) RawMaxSizeOrDefault() int64 {
+	if c.RawMaxSize > 0 {
+		return c.RawMaxSize
+	}
+	return DefaultRawMaxSize
+}
+
+// ProxyProtocolHeaderTimeoutOrDefault returns the PROXY protocol header timeout or a default.
+func (c Config) ProxyProtocolHeaderTimeoutOrDefault() time.Duration {
+	if c.ProxyProtocolHeaderTimeout > 0 {
+		return c.ProxyProtocolHeaderTimeout
+	}
+	return DefaultProxyProtocolHeaderTimeout
+}
+
+// CompressesContentType returns if a content type is in the compression allowlist.
+func (c Config) CompressesContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.CompressionContentTypesOrDefault() {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve resolves the config from the environment.
+func (c *Config) Resolve() error {
+	return env.Env().ReadInto(c)
+}
+
+// EnvVarNames returns the names of the environment variables, sorted so the output (and its etag) is stable.
+// Only names are returned; values can hold secrets and are never reflected back to callers.
+func EnvVarNames() []string {
+	names := env.Env().Vars()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// NewConnTracker returns a new connection tracker for the config.
+func NewConnTracker(cfg Config) *ConnTracker {
+	return &ConnTracker{
+		Config: cfg,
+		conns:  map[net.Conn]*TrackedConn{},
+	}
+}
+
+// ConnTracker tracks the server's connections through its `ConnContext` and `ConnState` hooks,
+// and counts the requests each serves.
+//
+// Connections are asked to close (with `Connection: close`) once they've served `ConnMaxRequests`
+// requests or are older than `ConnMaxAge`, so clients have to reconnect.
+type ConnTracker struct {
+	Config Config
+
+	sync.Mutex
+	conns  map[net.Conn]*TrackedConn
+	lastID uint64
+}
+
+// TrackedConn is a tracked connection.
+type TrackedConn struct {
+	ID         uint64
+	AcceptedAt time.Time
+	Requests   int
+	State      http.ConnState
+	// LocalAddr and RemoteAddr are read when the connection first becomes active,
+	// when any PROXY protocol header has been read.
+	LocalAddr  string
+	RemoteAddr string
+}
+
+// ConnInfo describes a tracked connection.
+type ConnInfo struct {
+	ID         uint64    `json:"id"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+	Age        string    `json:"age"`
+	// Requests is how many requests the connection has served, including any in progress.
+	Requests   int    `json:"requests"`
+	State      string `json:"state"`
+	LocalAddr  string `json:"localAddr,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Closing is if the connection closes after the current response.
+	Closing bool `json:"closing"`
+}
+
+type trackedConnKey struct{}
+
+// ConnContext starts tracking a connection and adds it to the context of its requests;
+// it's called for new connections, before `ConnState`.
+func (ct *ConnTracker) ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if ct == nil {
+		return ctx
+	}
+	ct.Lock()
+	defer ct.Unlock()
+	ct.lastID++
+	tracked := &TrackedConn{ID: ct.lastID, AcceptedAt: time.Now().UTC(), State: http.StateNew}
+	ct.conns[conn] = tracked
+	return context.WithValue(ctx, trackedConnKey{}, tracked)
+}
+
+// ConnState tracks a connection's state, and stops tracking it once it's closed or hijacked.
+func (ct *ConnTracker) ConnState(conn net.Conn, state http.ConnState) {
+	if ct == nil {
+		return
+	}
+	var localAddr, remoteAddr string
+	if state == http.StateActive {
+		localAddr, remoteAddr = conn.LocalAddr().String(), conn.RemoteAddr().String()
+	}
+
+	ct.Lock()
+	defer ct.Unlock()
+	tracked, ok := ct.conns[conn]
+	if !ok {
+		return
+	}
+	if state == http.StateClosed || state == http.StateHijacked {
+		delete(ct.conns, conn)
+	}
+	tracked.State = state
+	if tracked.RemoteAddr == "" {
+		tracked.LocalAddr, tracked.RemoteAddr = localAddr, remoteAddr
+	}
+}
+
+// Request counts a request against its connection, and asks for the connection to be closed
+// after the response if it has served enough requests or is old enough.
+func (ct *ConnTracker) Request(w http.ResponseWriter, req *http.Request) {
+	if ct == nil {
+		return
+	}
+	tracked, ok := req.Context().Value(trackedConnKey{}).(*TrackedConn)
+	if !ok {
+		return
+	}
+	ct.Lock()
+	tracked.Requests++
+	closing := ct.closing(tracked, time.Now().UTC())
+	ct.Unlock()
+	if closing {
+		w.Header().Set(web.HeaderConnection, "close")
+	}
+}
+
+// closing returns if a connection should close after its current response.
+func (ct *ConnTracker) closing(tracked *TrackedConn, now time.Time) bool {
+	if max := ct.Config.ConnMaxRequests; max > 0 && tracked.Requests >= max {
+		return true
+	}
+	if max := ct.Config.ConnMaxAge; max > 0 && now.Sub(tracked.AcceptedAt) >= max {
+		return true
+	}
+	return false
+}
+
+// info describes a tracked connection; the tracker must be locked.
+func (ct *ConnTracker) info(tracked *TrackedConn, now time.Time) ConnInfo {
+	return ConnInfo{
+		ID:         tracked.ID,
+		AcceptedAt: tracked.AcceptedAt,
+		Age:        now.Sub(tracked.AcceptedAt).Round(time.Millisecond).String(),
+		Requests:   tracked.Requests,
+		State:      tracked.State.String(),
+		LocalAddr:  tracked.LocalAddr,
+		RemoteAddr: tracked.RemoteAddr,
+		Closing:    ct.closing(tracked, now),
+	}
+}
+
+// Connections returns the tracked connections, oldest first.
+func (ct *ConnTracker) Connections() []ConnInfo {
+	ct.Lock()
+	defer ct.Unlock()
+	now := time.Now().UTC()
+	output := make([]ConnInfo, 0, len(ct.conns))
+	for _, tracked := range ct.conns {
+		output = append(output, ct.info(tracked, now))
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].ID < output[j].ID })
+	return output
+}
+
+// Register implements web.Controller.
+func (ct *ConnTracker) Register(app *web.App) {
+	app.GET("/conn", ct.conn)
+	app.GET("/admin/connections", ct.connections, AdminRequired(ct.Config))
+}
+
+// conn describes the request's connection.
+func (ct *ConnTracker) conn(r *web.Ctx) web.Result {
+	tracked, ok := r.Request.Context().Value(trackedConnKey{}).(*TrackedConn)
+	if !ok {
+		return web.Text.Status(http.StatusNotImplemented, "connections are not tracked")
+	}
+	ct.Lock()
+	info := ct.info(tracked, time.Now().UTC())
+	ct.Unlock()
+	return Negotiated(r, FormatJSON, info)
+}
+
+// connections describes every tracked connection.
+func (ct *ConnTracker) connections(r *web.Ctx) web.Result {
+	return Negotiated(r, FormatJSON, ct.Connections())
+}
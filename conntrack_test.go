@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// startServer serves a server's handler, with its connection hooks, as `Server.Start` does, returning its address.
+func startServer(t *testing.T, server *Server) string {
+	t.Helper()
+	httpServer := httptest.NewUnstartedServer(server.Handler())
+	httpServer.Config.ConnContext = server.ConnContext
+	httpServer.Config.ConnState = server.Connections.ConnState
+	httpServer.Start()
+	t.Cleanup(httpServer.Close)
+	return httpServer.Listener.Addr().String()
+}
+
+// connRequest writes a request for a path on a connection and reads the response,
+// returning it with its body read.
+func connRequest(t *testing.T, conn net.Conn, reader *bufio.Reader, path string) (*http.Response, []byte) {
+	t.Helper()
+	if _, err := fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: echo\r\n\r\n", path); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
+
+func TestConnTrackerMaxRequests(t *testing.T) {
+	cfg := Config{ConnMaxRequests: 3}
+	connections := NewConnTracker(cfg)
+	app := web.New()
+	app.Register(connections)
+	server := NewServer(app)
+	server.Connections = connections
+	addr := startServer(t, server)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for request := 1; request <= cfg.ConnMaxRequests; request++ {
+		res, body := connRequest(t, conn, reader, "/conn")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: unexpected status %d: %s", request, res.StatusCode, body)
+		}
+		var info ConnInfo
+		if err := json.Unmarshal(body, &info); err != nil {
+			t.Fatalf("request %d: %v", request, err)
+		}
+		closing := request == cfg.ConnMaxRequests
+		if info.Requests != request || info.Closing != closing || res.Close != closing {
+			t.Fatalf("request %d: expected %d requests and closing %v, got %+v (Connection: %q)", request, request, closing, info, res.Header.Get(web.HeaderConnection))
+		}
+	}
+
+	// the server closes the connection after the last response.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(connections.Connections()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the closed connection to stop being tracked, got %+v", connections.Connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnTrackerClosing(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := [...]struct {
+		Name     string
+		Config   Config
+		Requests int
+		Age      time.Duration
+		Expected bool
+	}{
+		{Name: "unlimited", Requests: 1000, Age: time.Hour},
+		{Name: "under max requests", Config: Config{ConnMaxRequests: 3}, Requests: 2},
+		{Name: "at max requests", Config: Config{ConnMaxRequests: 3}, Requests: 3, Expected: true},
+		{Name: "under max age", Config: Config{ConnMaxAge: time.Minute}, Requests: 1, Age: 59 * time.Second},
+		{Name: "at max age", Config: Config{ConnMaxAge: time.Minute}, Requests: 1, Age: time.Minute, Expected: true},
+		{Name: "either", Config: Config{ConnMaxRequests: 100, ConnMaxAge: time.Minute}, Requests: 1, Age: time.Hour, Expected: true},
+	}
+	for _, tc := range testCases {
+		ct := NewConnTracker(tc.Config)
+		tracked := &TrackedConn{AcceptedAt: now.Add(-tc.Age), Requests: tc.Requests}
+		if actual := ct.closing(tracked, now); actual != tc.Expected {
+			t.Errorf("%s: expected %v, got %v", tc.Name, tc.Expected, actual)
+		}
+	}
+}
+
+func TestConnTrackerMaxAge(t *testing.T) {
+	connections := NewConnTracker(Config{ConnMaxAge: 100 * time.Millisecond})
+	app := web.New()
+	app.Register(connections)
+	server := NewServer(app)
+	server.Connections = connections
+	addr := startServer(t, server)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if res, _ := connRequest(t, conn, reader, "/conn"); res.Close {
+		t.Fatal("expected a new connection to be kept open")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if res, _ := connRequest(t, conn, reader, "/conn"); !res.Close {
+		t.Fatal("expected an old connection to be closed")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+// SameSiteNone is the `SameSite=None` value, which `webutil.ParseSameSite` doesn't handle.
+const SameSiteNone = "none"
+
+// cookieAttributeParams are the `/cookies/set` query parameters that are cookie attributes rather than cookies.
+var cookieAttributeParams = map[string]bool{
+	"domain":      true,
+	"path":        true,
+	"maxAge":      true,
+	"sameSite":    true,
+	"secure":      true,
+	"httpOnly":    true,
+	"partitioned": true,
+}
+
+// Cookies is a controller for inspecting and manipulating cookies.
+type Cookies struct{}
+
+// Register implements web.Controller.
+func (c Cookies) Register(app *web.App) {
+	app.GET("/cookies", c.list)
+	app.GET("/cookies/set", c.set)
+	app.GET("/cookies/delete", c.delete)
+}
+
+// CookiesResponse lists the cookies a request sent.
+type CookiesResponse struct {
+	Cookies map[string]string `json:"cookies"`
+	// Raw are the `Cookie` headers as sent; useful when names repeat across paths or partitions.
+	Raw []string `json:"raw,omitempty"`
+}
+
+func (c Cookies) list(r *web.Ctx) web.Result {
+	response := CookiesResponse{
+		Cookies: map[string]string{},
+		Raw:     r.Request.Header["Cookie"],
+	}
+	for _, cookie := range r.Request.Cookies() {
+		response.Cookies[cookie.Name] = cookie.Value
+	}
+	return Negotiated(r, FormatJSON, response)
+}
+
+// set sets a cookie for each query parameter that isn't an attribute, then redirects to `/cookies`.
+// Without `?domain=` the cookies are host-only.
+func (c Cookies) set(r *web.Ctx) web.Result {
+	query := r.Request.URL.Query()
+
+	template := http.Cookie{
+		Domain: query.Get("domain"),
+		Path:   query.Get("path"),
+	}
+	if template.Path == "" {
+		template.Path = "/"
+	}
+	if query.Get("maxAge") != "" {
+		maxAge, err := web.IntValue(query.Get("maxAge"), nil)
+		if err != nil {
+			return web.JSON.BadRequest(ex.New("invalid maxAge", ex.OptInner(err)))
+		}
+		if maxAge == 0 {
+			maxAge = -1 // `Max-Age=0` means delete; http.Cookie spells that -1.
+		}
+		template.MaxAge = maxAge
+	}
+	if sameSite := query.Get("sameSite"); sameSite != "" {
+		value, err := ParseSameSite(sameSite)
+		if err != nil {
+			return web.JSON.BadRequest(err)
+		}
+		template.SameSite = value
+	}
+	var err error
+	if template.Secure, err = web.BoolValue(query.Get("secure"), nil); err != nil && query.Get("secure") != "" {
+		return web.JSON.BadRequest(ex.New("invalid secure", ex.OptInner(err)))
+	}
+	if template.HttpOnly, err = web.BoolValue(query.Get("httpOnly"), nil); err != nil && query.Get("httpOnly") != "" {
+		return web.JSON.BadRequest(ex.New("invalid httpOnly", ex.OptInner(err)))
+	}
+	if template.Partitioned, err = web.BoolValue(query.Get("partitioned"), nil); err != nil && query.Get("partitioned") != "" {
+		return web.JSON.BadRequest(ex.New("invalid partitioned", ex.OptInner(err)))
+	}
+
+	for name, values := range query {
+		if cookieAttributeParams[name] {
+			continue
+		}
+		for _, value := range values {
+			cookie := template
+			cookie.Name, cookie.Value = name, value
+			c.write(r, &cookie)
+		}
+	}
+	return web.RedirectWithMethod(http.MethodGet, "/cookies")
+}
+
+// delete expires the named cookies (`?name=`, repeatable), then redirects to `/cookies`.
+// `?path=` and `?domain=` must match the attributes the cookie was set with.
+func (c Cookies) delete(r *web.Ctx) web.Result {
+	query := r.Request.URL.Query()
+	names := query["name"]
+	if len(names) == 0 {
+		return web.JSON.BadRequest(ex.New("missing `name` parameter"))
+	}
+	path := query.Get("path")
+	if path == "" {
+		path = "/"
+	}
+
+	for _, name := range names {
+		if domain := query.Get("domain"); domain != "" {
+			// ExpireCookie always uses the request's cookie domain.
+			r.WriteNewCookie(&http.Cookie{
+				Name:    name,
+				Path:    path,
+				Domain:  domain,
+				Expires: time.Unix(0, 0),
+				MaxAge:  -1,
+			})
+			continue
+		}
+		r.ExpireCookie(name, path)
+	}
+	return web.RedirectWithMethod(http.MethodGet, "/cookies")
+}
+
+// write sets a cookie; `WriteNewCookie` fills in a missing domain with the request host,
+// which would turn a host-only cookie into a domain cookie, so those are set directly.
+func (c Cookies) write(r *web.Ctx, cookie *http.Cookie) {
+	if cookie.Domain == "" {
+		http.SetCookie(r.Response, cookie)
+		return
+	}
+	r.WriteNewCookie(cookie)
+}
+
+// ParseSameSite parses a `SameSite` value, including `none`, case insensitively.
+func ParseSameSite(value string) (http.SameSite, error) {
+	value = strings.ToLower(value)
+	if value == SameSiteNone {
+		return http.SameSiteNoneMode, nil
+	}
+	return webutil.ParseSameSite(value)
+}
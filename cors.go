@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// CORS headers.
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// DefaultCORSAllowedMethods are the methods allowed cross origin by default.
+var DefaultCORSAllowedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// NewCORS returns a new CORS policy from the config, compiling its allowed origins.
+func NewCORS(cfg Config) (*CORS, error) {
+	cors := &CORS{Config: cfg}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		expr, err := compileOriginPattern(origin)
+		if err != nil {
+			return nil, ex.New("invalid CORS allowed origin", ex.OptMessagef("origin: %s", origin), ex.OptInner(err))
+		}
+		cors.origins = append(cors.origins, corsOrigin{Pattern: origin, expr: expr})
+	}
+	return cors, nil
+}
+
+// CORS is the cross origin resource sharing policy; its middleware adds the CORS headers to responses
+// and answers preflight requests. It is disabled unless allowed origins are configured.
+type CORS struct {
+	Config  Config
+	origins []corsOrigin
+}
+
+// corsOrigin is an allowed origin pattern.
+type corsOrigin struct {
+	Pattern string
+	expr    *regexp.Regexp
+}
+
+// compileOriginPattern compiles an allowed origin; `~` prefixes a regular expression,
+// a `*` matches any run of characters other than `/`, and anything else must match exactly.
+// Regular expressions must match the whole origin too, or `~https://.*\.example\.com`
+// would allow `https://a.example.com.evil.com`.
+func compileOriginPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "~") {
+		return regexp.Compile(`^(?:` + pattern[1:] + `)$`)
+	}
+	if pattern == "*" {
+		return regexp.MustCompile(`.*`), nil
+	}
+	quoted := strings.Replace(regexp.QuoteMeta(pattern), `\*`, `[^/]*`, -1)
+	return regexp.Compile(`(?i)^` + quoted + `$`)
+}
+
+// Enabled returns if any origins are allowed.
+func (c *CORS) Enabled() bool {
+	return len(c.origins) > 0
+}
+
+// CORSDecision is the evaluation of a request against the CORS policy.
+type CORSDecision struct {
+	Enabled        bool     `json:"enabled"`
+	Origin         string   `json:"origin,omitempty"`
+	Preflight      bool     `json:"preflight"`
+	RequestMethod  string   `json:"requestMethod,omitempty"`
+	RequestHeaders []string `json:"requestHeaders,omitempty"`
+	Allowed        bool     `json:"allowed"`
+	// MatchedOrigin is the allowed origin pattern the origin matched.
+	MatchedOrigin string `json:"matchedOrigin,omitempty"`
+	// Reason is why the request isn't allowed.
+	Reason string `json:"reason,omitempty"`
+	// Headers are the CORS response headers.
+	Headers http.Header `json:"headers,omitempty"`
+}
+
+// IsPreflight returns if a request is a CORS preflight; an `OPTIONS` request with an `Origin`
+// and an `Access-Control-Request-Method`.
+func IsPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get(HeaderOrigin) != "" && req.Header.Get(HeaderAccessControlRequestMethod) != ""
+}
+
+// Evaluate evaluates a request against the policy.
+func (c *CORS) Evaluate(req *http.Request) (decision CORSDecision) {
+	decision.Enabled = c.Enabled()
+	decision.Origin = req.Header.Get(HeaderOrigin)
+	decision.RequestMethod = req.Header.Get(HeaderAccessControlRequestMethod)
+	decision.Preflight = IsPreflight(req)
+	for _, value := range req.Header.Values(HeaderAccessControlRequestHeaders) {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				decision.RequestHeaders = append(decision.RequestHeaders, name)
+			}
+		}
+	}
+
+	if !decision.Enabled {
+		decision.Reason = "CORS is not configured; set CORS_ALLOWED_ORIGINS"
+		return
+	}
+	decision.Headers = http.Header{}
+	if !c.anyOrigin() {
+		decision.Headers.Set(web.HeaderVary, HeaderOrigin)
+	}
+	if decision.Origin == "" {
+		decision.Reason = "no Origin header; not a cross origin request"
+		return
+	}
+	for _, origin := range c.origins {
+		if origin.expr.MatchString(decision.Origin) {
+			decision.MatchedOrigin = origin.Pattern
+			break
+		}
+	}
+	if decision.MatchedOrigin == "" {
+		decision.Reason = fmt.Sprintf("origin %q is not allowed", decision.Origin)
+		return
+	}
+
+	if decision.Preflight {
+		if !stringsContain(c.Config.CORSAllowedMethodsOrDefault(), decision.RequestMethod) {
+			decision.Reason = fmt.Sprintf("method %q is not allowed", decision.RequestMethod)
+			return
+		}
+		allowedHeaders, ok := c.allowedHeaders(decision.RequestHeaders)
+		if !ok {
+			decision.Reason = fmt.Sprintf("headers %q are not allowed", c.disallowedHeaders(decision.RequestHeaders))
+			return
+		}
+		decision.Headers.Set(HeaderAccessControlAllowMethods, strings.Join(c.Config.CORSAllowedMethodsOrDefault(), ", "))
+		if len(allowedHeaders) > 0 {
+			decision.Headers.Set(HeaderAccessControlAllowHeaders, strings.Join(allowedHeaders, ", "))
+		}
+		if maxAge := c.Config.CORSMaxAge; maxAge > 0 {
+			decision.Headers.Set(HeaderAccessControlMaxAge, strconv.Itoa(int(maxAge.Seconds())))
+		}
+	} else if len(c.Config.CORSExposedHeaders) > 0 {
+		decision.Headers.Set(HeaderAccessControlExposeHeaders, strings.Join(c.Config.CORSExposedHeaders, ", "))
+	}
+
+	decision.Allowed = true
+	// a `*` origin doesn't work with credentials, so the origin is echoed instead.
+	if c.anyOrigin() && !c.Config.CORSAllowCredentials {
+		decision.Headers.Set(HeaderAccessControlAllowOrigin, "*")
+	} else {
+		decision.Headers.Set(HeaderAccessControlAllowOrigin, decision.Origin)
+		decision.Headers.Set(web.HeaderVary, HeaderOrigin)
+	}
+	if c.Config.CORSAllowCredentials {
+		decision.Headers.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+	return
+}
+
+// anyOrigin returns if the only allowed origin is `*`.
+func (c *CORS) anyOrigin() bool {
+	return len(c.origins) == 1 && c.origins[0].Pattern == "*"
+}
+
+// allowedHeaders returns the `Access-Control-Allow-Headers` for a preflight's requested headers;
+// without configured headers (or with `*` and credentials, where a literal `*` doesn't work)
+// the requested headers are reflected.
+func (c *CORS) allowedHeaders(requested []string) ([]string, bool) {
+	allowed := c.Config.CORSAllowedHeaders
+	if len(allowed) == 0 || (stringsContain(allowed, "*") && c.Config.CORSAllowCredentials) {
+		return requested, true
+	}
+	if stringsContain(allowed, "*") {
+		return []string{"*"}, true
+	}
+	return allowed, len(c.disallowedHeaders(requested)) == 0
+}
+
+func (c *CORS) disallowedHeaders(requested []string) (output []string) {
+	for _, name := range requested {
+		var found bool
+		for _, allowed := range c.Config.CORSAllowedHeaders {
+			if allowed == "*" || strings.EqualFold(allowed, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			output = append(output, name)
+		}
+	}
+	return
+}
+
+// Middleware is web middleware that adds the CORS headers to responses and answers preflight requests,
+// with 204 if they're allowed and 403 with the reason if they're not.
+func (c *CORS) Middleware(action web.Action) web.Action {
+	return func(r *web.Ctx) web.Result {
+		if !c.Enabled() {
+			return action(r)
+		}
+		decision := c.Evaluate(r.Request)
+		header := r.Response.Header()
+		for name, values := range decision.Headers {
+			if name == web.HeaderVary {
+				addVary(header, values[0])
+				continue
+			}
+			header[name] = values
+		}
+		if decision.Preflight {
+			if !decision.Allowed {
+				return web.Text.Status(http.StatusForbidden, decision.Reason)
+			}
+			return web.NoContent
+		}
+		return action(r)
+	}
+}
+
+// Register implements web.Controller.
+func (c *CORS) Register(app *web.App) {
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.POST, app.PUT, app.PATCH, app.DELETE} {
+		register("/cors", c.cors)
+	}
+}
+
+// cors reports how the request was evaluated against the policy.
+func (c *CORS) cors(r *web.Ctx) web.Result {
+	return Negotiated(r, FormatJSON, c.Evaluate(r.Request))
+}
+
+// RegisterPreflight registers an OPTIONS route for every path that doesn't have one, so the middleware
+// can answer preflight requests for them; the app only routes OPTIONS requests that have a route.
+// It must be called after every other route is registered.
+//
+// Plain OPTIONS requests are answered as the app would without the routes;
+// with an `Allow` header if `HandleOptions` is set, or 404 otherwise.
+func (c *CORS) RegisterPreflight(app *web.App) {
+	if !c.Enabled() {
+		return
+	}
+	methods := map[string][]string{}
+	for method, root := range app.Routes {
+		if method == http.MethodOptions {
+			continue
+		}
+		walkRoutes(root, func(route *web.Route) {
+			methods[route.Path] = append(methods[route.Path], method)
+		})
+	}
+	if root := app.Routes[http.MethodOptions]; root != nil {
+		walkRoutes(root, func(route *web.Route) {
+			delete(methods, route.Path)
+		})
+	}
+
+	for path, allowed := range methods {
+		sort.Strings(allowed)
+		allow := strings.Join(append(allowed, http.MethodOptions), ", ")
+		app.OPTIONS(path, func(r *web.Ctx) web.Result {
+			if !app.Config.HandleOptions {
+				return r.DefaultProvider.NotFound()
+			}
+			r.Response.Header().Set(web.HeaderAllow, allow)
+			r.Response.WriteHeader(http.StatusOK)
+			return nil
+		})
+	}
+}
+
+// walkRoutes calls a function for each route in a route tree.
+func walkRoutes(node *web.RouteNode, handler func(*web.Route)) {
+	if node == nil {
+		return
+	}
+	if node.Route != nil {
+		handler(node.Route)
+	}
+	for _, child := range node.Children {
+		walkRoutes(child, handler)
+	}
+}
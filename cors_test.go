@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSOrigins(t *testing.T) {
+	testCases := [...]struct {
+		Pattern  string
+		Origin   string
+		Expected bool
+	}{
+		{Pattern: "https://app.example.com", Origin: "https://app.example.com", Expected: true},
+		{Pattern: "https://app.example.com", Origin: "HTTPS://APP.EXAMPLE.COM", Expected: true},
+		{Pattern: "https://app.example.com", Origin: "https://app.example.com.evil.com"},
+		{Pattern: "https://app.example.com", Origin: "https://evil.com/https://app.example.com"},
+		{Pattern: "https://*.example.com", Origin: "https://a.example.com", Expected: true},
+		{Pattern: "https://*.example.com", Origin: "https://a.example.com.evil.com"},
+		{Pattern: "https://*.example.com", Origin: "https://evil.com/.example.com"},
+		{Pattern: "*", Origin: "https://anything.test", Expected: true},
+		{Pattern: `~https://.*\.example\.com`, Origin: "https://a.example.com", Expected: true},
+		{Pattern: `~https://.*\.example\.com`, Origin: "https://a.example.com.evil.com"},
+		{Pattern: `~https://(a|b)\.example\.com`, Origin: "https://b.example.com", Expected: true},
+		{Pattern: `~https://(a|b)\.example\.com`, Origin: "https://c.example.com"},
+		{Pattern: `~https://a\.example\.com|https://b\.example\.com`, Origin: "https://a.example.com.evil.com"},
+		{Pattern: `~https://a\.example\.com|https://b\.example\.com`, Origin: "https://evil.com#https://b.example.com"},
+	}
+	for _, tc := range testCases {
+		cors, err := NewCORS(Config{CORSAllowedOrigins: []string{tc.Pattern}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(HeaderOrigin, tc.Origin)
+		if decision := cors.Evaluate(req); decision.Allowed != tc.Expected {
+			t.Errorf("%s %s: expected %v, got %v (%s)", tc.Pattern, tc.Origin, tc.Expected, decision.Allowed, decision.Reason)
+		}
+	}
+}
+
+func TestCORSInvalidOrigin(t *testing.T) {
+	if _, err := NewCORS(Config{CORSAllowedOrigins: []string{"~("}}); err == nil {
+		t.Error("expected an invalid regular expression to be an error")
+	}
+}
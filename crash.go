@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// DefaultOOMChunkSize is the default size of each allocation made by the oom route.
+	DefaultOOMChunkSize = fileutil.Megabyte
+)
+
+// Crash is a controller for admin routes that crash or terminate the process.
+// They exist to exercise restart policies, crash-loop backoff and log pipelines.
+type Crash struct {
+	Config Config
+	Log    *logger.Logger
+
+	gate sync.RWMutex
+}
+
+// Register implements web.Controller.
+func (c *Crash) Register(app *web.App) {
+	admin := AdminRequired(c.Config)
+	app.POST("/admin/crash/panic", c.panic, admin)
+	app.POST("/admin/crash/exit/:code", c.exit, admin)
+	app.POST("/admin/crash/deadlock", c.deadlock, admin)
+	app.POST("/admin/crash/oom", c.oom, admin)
+}
+
+// Gate is a middleware that holds a read lock on the crash gate for the duration of the request.
+// It must be installed as default middleware so the deadlock route can block every request.
+func (c *Crash) Gate(action web.Action) web.Action {
+	return func(r *web.Ctx) web.Result {
+		c.gate.RLock()
+		defer c.gate.RUnlock()
+		return action(r)
+	}
+}
+
+// panic panics on a new goroutine, which `App.recover` cannot catch.
+func (c *Crash) panic(r *web.Ctx) web.Result {
+	delay, err := c.delay(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	c.accepted(r, "panicking in %v\n", delay)
+	go func() {
+		time.Sleep(delay)
+		panic("echo: intentional panic outside the request recovery path")
+	}()
+	return nil
+}
+
+// exit exits the process with the code given by the route after an optional delay.
+func (c *Crash) exit(r *web.Ctx) web.Result {
+	code, err := web.IntValue(r.RouteParam("code"))
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	if code < 0 || code > 255 {
+		return web.Text.BadRequest(ex.New("exit code must be between 0 and 255", ex.OptMessagef("code: %d", code)))
+	}
+	delay, err := c.delay(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	c.accepted(r, "exiting with code %d in %v\n", code, delay)
+	go func() {
+		time.Sleep(delay)
+		if c.Log != nil {
+			c.Log.Fatalf("exiting with code %d", code)
+			c.Log.Drain()
+		}
+		os.Exit(code)
+	}()
+	return nil
+}
+
+// deadlock takes the write side of the crash gate while holding its read side.
+// Every request in flight or arriving afterwards blocks forever.
+// The runtime's deadlock detector will not fire because the listener keeps the netpoller alive,
+// so the process stays up but unresponsive.
+func (c *Crash) deadlock(r *web.Ctx) web.Result {
+	c.accepted(r, "deadlocking\n")
+	c.gate.Lock()
+	return nil
+}
+
+// oom allocates (and touches) memory until the process is killed.
+func (c *Crash) oom(r *web.Ctx) web.Result {
+	chunkSize := DefaultOOMChunkSize
+	if value, err := r.QueryValue("chunk"); err == nil {
+		if chunkSize = fileutil.ParseFileSize(value); chunkSize <= 0 {
+			return web.Text.BadRequest(ex.New("invalid chunk size", ex.OptMessagef("chunk: %s", value)))
+		}
+	}
+	var interval time.Duration
+	if value, err := r.QueryValue("interval"); err == nil {
+		if interval, err = web.DurationValue(value, nil); err != nil {
+			return web.Text.BadRequest(err)
+		}
+	}
+	c.accepted(r, "allocating %s every %v\n", fileutil.FormatFileSize(chunkSize), interval)
+	go func() {
+		// the goroutine never returns, so its ballast is never collected; each request has its own.
+		var ballast [][]byte
+		for {
+			chunk := make([]byte, chunkSize)
+			for index := range chunk {
+				chunk[index] = byte(index)
+			}
+			ballast = append(ballast, chunk)
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+		}
+	}()
+	return nil
+}
+
+// delay reads the optional `delay` query parameter.
+func (c *Crash) delay(r *web.Ctx) (time.Duration, error) {
+	if value, err := r.QueryValue("delay"); err == nil {
+		return web.DurationValue(value, nil)
+	}
+	return 0, nil
+}
+
+// accepted writes and flushes a 202 before the process is taken down.
+func (c *Crash) accepted(r *web.Ctx, format string, args ...interface{}) {
+	r.Response.Header().Set(web.HeaderContentType, web.ContentTypeText)
+	r.Response.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(r.Response, format, args...)
+	r.Response.Flush()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// DefaultTraceDuration is the default execution trace capture duration.
+	DefaultTraceDuration = time.Second
+	// MaxTraceDuration is the longest execution trace capture we allow.
+	MaxTraceDuration = time.Minute
+)
+
+// Debug is a controller for admin diagnostics routes; pprof, goroutine dumps, heap profiles and execution traces.
+type Debug struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+// Routes are only registered if debug routes are enabled.
+func (d Debug) Register(app *web.App) {
+	if !d.Config.DebugEnabled {
+		return
+	}
+	admin := AdminRequired(d.Config)
+	app.GET("/debug/pprof/*profile", d.pprof, admin)
+	app.POST("/debug/pprof/*profile", d.pprof, admin)
+	app.GET("/debug/goroutines", d.goroutines, admin)
+	app.GET("/debug/heap", d.heap, admin)
+	app.GET("/debug/trace", d.trace, admin)
+}
+
+// pprof dispatches to the `net/http/pprof` handlers by profile name.
+func (d Debug) pprof(r *web.Ctx) web.Result {
+	profile, _ := r.RouteParam("profile")
+	switch profile = strings.TrimPrefix(profile, "/"); profile {
+	case "":
+		pprof.Index(r.Response, r.Request)
+	case "cmdline":
+		pprof.Cmdline(r.Response, r.Request)
+	case "profile":
+		pprof.Profile(r.Response, r.Request)
+	case "symbol":
+		pprof.Symbol(r.Response, r.Request)
+	case "trace":
+		pprof.Trace(r.Response, r.Request)
+	default:
+		pprof.Handler(profile).ServeHTTP(r.Response, r.Request)
+	}
+	return nil
+}
+
+// goroutines writes the stacks of all goroutines as text.
+func (d Debug) goroutines(r *web.Ctx) web.Result {
+	return web.Text.Result(GoroutineDump())
+}
+
+// heap downloads a heap profile, optionally running a gc first with `?gc=true`.
+func (d Debug) heap(r *web.Ctx) web.Result {
+	if gc, _ := web.BoolValue(r.QueryValue("gc")); gc {
+		runtime.GC()
+	}
+	buffer := new(bytes.Buffer)
+	if err := rpprof.Lookup("heap").WriteTo(buffer, 0); err != nil {
+		return web.Text.InternalError(err)
+	}
+	d.attachment(r, "heap", "pprof")
+	return web.RawWithContentType("application/octet-stream", buffer.Bytes())
+}
+
+// trace captures an execution trace for `?seconds=` (default one) and downloads it.
+func (d Debug) trace(r *web.Ctx) web.Result {
+	duration := DefaultTraceDuration
+	if seconds, err := web.Float64Value(r.QueryValue("seconds")); err == nil {
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	if duration <= 0 || duration > MaxTraceDuration {
+		return web.Text.BadRequest(ex.New("invalid trace duration", ex.OptMessagef("duration must be between 0 and %v", MaxTraceDuration)))
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := trace.Start(buffer); err != nil {
+		return web.Text.InternalError(err)
+	}
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+	trace.Stop()
+
+	d.attachment(r, "trace", "out")
+	return web.RawWithContentType("application/octet-stream", buffer.Bytes())
+}
+
+func (d Debug) attachment(r *web.Ctx, name, extension string) {
+	r.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().UTC().Format("20060102T150405Z"), extension))
+}
+
+// GoroutineDump returns the stacks of all goroutines.
+func GoroutineDump() string {
+	buffer := new(bytes.Buffer)
+	rpprof.Lookup("goroutine").WriteTo(buffer, 2)
+	return buffer.String()
+}
+
+// DumpGoroutinesOnSignal writes a goroutine dump to the log whenever the process receives SIGQUIT or SIGUSR1.
+// Unlike the runtime's default SIGQUIT handling, the process keeps running.
+// It runs alongside `graceful.Shutdown`, which only listens for SIGINT and SIGTERM.
+func DumpGoroutinesOnSignal(log logger.Log) {
+	dump := make(chan os.Signal, 1)
+	signal.Notify(dump, syscall.SIGQUIT, syscall.SIGUSR1)
+	go func() {
+		for sig := range dump {
+			logger.MaybeInfof(log, "goroutine dump (%v)\n%s", sig, GoroutineDump())
+		}
+	}()
+}
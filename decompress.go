@@ -0,0 +1,99 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// DefaultRequestMaxDecompressedSize is the largest a request body may decompress to.
+	DefaultRequestMaxDecompressedSize = 32 * fileutil.Megabyte
+)
+
+// DecompressRequest returns web middleware that decodes gzip and deflate request bodies,
+// so `Ctx.PostBody`, `PostBodyAsJSON` and form parsing see the decoded content.
+//
+// Stacked encodings (`Content-Encoding: deflate, gzip`) are decoded in reverse order.
+// Unsupported encodings are rejected with 415, and reading past the decompressed size limit fails
+// the read, and the request with 413 (whatever the handler made of the failed read).
+// The `Content-Encoding` header is left as sent so it can still be echoed.
+//
+// Requests for the skipped routes are left as they were sent, for handlers that read bodies from the wire
+// themselves; decoders read ahead, and the decoded body has no content length.
+func DecompressRequest(cfg Config, skipRoutes ...string) web.Middleware {
+	return func(action web.Action) web.Action {
+		return func(r *web.Ctx) web.Result {
+			if r.Route != nil && stringsContain(skipRoutes, r.Route.Path) {
+				return action(r)
+			}
+			encodings := requestContentEncodings(r.Request)
+			if len(encodings) == 0 || r.Request.Body == nil || r.Request.Body == http.NoBody {
+				return action(r)
+			}
+
+			body := r.Request.Body
+			for index := len(encodings) - 1; index >= 0; index-- {
+				var err error
+				switch encodings[index] {
+				case ContentEncodingGZIP, "x-gzip":
+					body, err = gzip.NewReader(body)
+				case ContentEncodingDeflate:
+					body, err = zlib.NewReader(body)
+				default:
+					r.Response.Header().Set(web.HeaderAcceptEncoding, strings.Join(compressionEncodings, ", "))
+					return web.JSON.Status(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported request content encoding %q", encodings[index]))
+				}
+				if err != nil {
+					return web.JSON.BadRequest(ex.New("invalid request body encoding", ex.OptMessagef("encoding: %s", encodings[index]), ex.OptInner(err)))
+				}
+			}
+
+			limited := &decompressedBody{ReadCloser: http.MaxBytesReader(r.Response, body, cfg.RequestMaxDecompressedSizeOrDefault())}
+			r.Request.Body = limited
+			r.Request.ContentLength = -1
+			r.Request.Header.Del(web.HeaderContentLength)
+			result := action(r)
+			if limited.tooLarge {
+				return web.JSON.Status(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body decompresses to more than %d bytes", cfg.RequestMaxDecompressedSizeOrDefault()))
+			}
+			return result
+		}
+	}
+}
+
+// decompressedBody is a decompressed request body that notes if it was read past its size limit.
+type decompressedBody struct {
+	io.ReadCloser
+	tooLarge bool
+}
+
+// Read implements io.Reader.
+func (db *decompressedBody) Read(contents []byte) (int, error) {
+	read, err := db.ReadCloser.Read(contents)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		db.tooLarge = true
+	}
+	return read, err
+}
+
+// requestContentEncodings returns the lowercased content codings of a request, ignoring `identity`.
+func requestContentEncodings(req *http.Request) (output []string) {
+	for _, value := range req.Header.Values(web.HeaderContentEncoding) {
+		for _, encoding := range strings.Split(value, ",") {
+			if encoding = strings.ToLower(strings.TrimSpace(encoding)); encoding != "" && encoding != web.ContentEncodingIdentity {
+				output = append(output, encoding)
+			}
+		}
+	}
+	return
+}
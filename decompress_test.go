@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blend/go-sdk/web"
+)
+
+func TestDecompressRequest(t *testing.T) {
+	app := web.New(web.OptUse(DecompressRequest(Config{RequestMaxDecompressedSize: 1024})))
+	app.POST("/echo", func(r *web.Ctx) web.Result {
+		body, err := ioutil.ReadAll(r.Request.Body)
+		if err != nil {
+			return web.Text.BadRequest(err)
+		}
+		return web.Text.Result(string(body))
+	})
+
+	gzipped := func(contents []byte) []byte {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		writer.Write(contents)
+		writer.Close()
+		return buffer.Bytes()
+	}
+
+	testCases := [...]struct {
+		Name     string
+		Encoding string
+		Body     []byte
+		Expected int
+	}{
+		{Name: "plain", Body: []byte("hello"), Expected: http.StatusOK},
+		{Name: "gzip", Encoding: "gzip", Body: gzipped([]byte("hello")), Expected: http.StatusOK},
+		{Name: "at the limit", Encoding: "gzip", Body: gzipped(make([]byte, 1024)), Expected: http.StatusOK},
+		{Name: "past the limit", Encoding: "gzip", Body: gzipped(make([]byte, 1025)), Expected: http.StatusRequestEntityTooLarge},
+		{Name: "bomb", Encoding: "gzip", Body: gzipped(make([]byte, 10<<20)), Expected: http.StatusRequestEntityTooLarge},
+		{Name: "invalid", Encoding: "gzip", Body: []byte("not gzip"), Expected: http.StatusBadRequest},
+		{Name: "unsupported", Encoding: "br", Body: []byte("hello"), Expected: http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(tc.Body))
+		if tc.Encoding != "" {
+			req.Header.Set(web.HeaderContentEncoding, tc.Encoding)
+		}
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		if res.Code != tc.Expected {
+			t.Errorf("%s: expected %d, got %d: %s", tc.Name, tc.Expected, res.Code, res.Body.String())
+		}
+	}
+}
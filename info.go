@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+// Info is a controller that reports runtime and container information.
+type Info struct {
+	AppStart   time.Time
+	CgroupRoot string
+}
+
+// Register implements web.Controller.
+func (i Info) Register(app *web.App) {
+	app.GET("/info", i.info)
+}
+
+// InfoResponse is the response for the info route.
+type InfoResponse struct {
+	Hostname   string       `json:"hostname"`
+	PodIP      string       `json:"podIP"`
+	Started    time.Time    `json:"started"`
+	Uptime     string       `json:"uptime"`
+	GoVersion  string       `json:"goVersion"`
+	Build      *BuildInfo   `json:"build,omitempty"`
+	GOMAXPROCS int          `json:"gomaxprocs"`
+	NumCPU     int          `json:"numCPU"`
+	Goroutines int          `json:"goroutines"`
+	MemStats   MemStatsInfo `json:"memStats"`
+	Cgroup     *Cgroup      `json:"cgroup,omitempty"`
+	CgroupErr  string       `json:"cgroupError,omitempty"`
+}
+
+// BuildInfo is the subset of `debug.BuildInfo` we report.
+type BuildInfo struct {
+	Path        string            `json:"path,omitempty"`
+	MainVersion string            `json:"mainVersion,omitempty"`
+	VCSRevision string            `json:"vcsRevision,omitempty"`
+	VCSTime     string            `json:"vcsTime,omitempty"`
+	VCSModified bool              `json:"vcsModified,omitempty"`
+	Settings    map[string]string `json:"settings,omitempty"`
+}
+
+// MemStatsInfo is the subset of `runtime.MemStats` we report.
+type MemStatsInfo struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapIdle     uint64 `json:"heapIdle"`
+	HeapReleased uint64 `json:"heapReleased"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	StackInuse   uint64 `json:"stackInuse"`
+	NextGC       uint64 `json:"nextGC"`
+	NumGC        uint32 `json:"numGC"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+}
+
+func (i Info) info(r *web.Ctx) web.Result {
+	hostname, _ := os.Hostname()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	response := InfoResponse{
+		Hostname:   hostname,
+		PodIP:      webutil.LocalIP(),
+		Started:    i.AppStart.UTC(),
+		Uptime:     time.Since(i.AppStart).String(),
+		GoVersion:  runtime.Version(),
+		Build:      readBuildInfo(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		MemStats: MemStatsInfo{
+			Alloc:        memStats.Alloc,
+			TotalAlloc:   memStats.TotalAlloc,
+			Sys:          memStats.Sys,
+			HeapAlloc:    memStats.HeapAlloc,
+			HeapInuse:    memStats.HeapInuse,
+			HeapIdle:     memStats.HeapIdle,
+			HeapReleased: memStats.HeapReleased,
+			HeapObjects:  memStats.HeapObjects,
+			StackInuse:   memStats.StackInuse,
+			NextGC:       memStats.NextGC,
+			NumGC:        memStats.NumGC,
+			PauseTotalNs: memStats.PauseTotalNs,
+		},
+	}
+
+	cgroup, err := ReadCgroup(i.cgroupRootOrDefault())
+	if err != nil {
+		response.CgroupErr = err.Error()
+	} else {
+		response.Cgroup = cgroup
+	}
+	return Negotiated(r, FormatJSON, response)
+}
+
+func (i Info) cgroupRootOrDefault() string {
+	if i.CgroupRoot != "" {
+		return i.CgroupRoot
+	}
+	return DefaultCgroupRoot
+}
+
+// readBuildInfo returns the build info embedded in the binary, if any.
+func readBuildInfo() *BuildInfo {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	output := BuildInfo{
+		Path:        buildInfo.Path,
+		MainVersion: buildInfo.Main.Version,
+		Settings:    map[string]string{},
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			output.VCSRevision = setting.Value
+		case "vcs.time":
+			output.VCSTime = setting.Value
+		case "vcs.modified":
+			output.VCSModified = setting.Value == "true"
+		default:
+			output.Settings[setting.Key] = setting.Value
+		}
+	}
+	return &output
+}
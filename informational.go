@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// HeaderLink is the `Link` header, which early hints carry.
+const HeaderLink = "Link"
+
+// HeaderExpect is the `Expect` header.
+const HeaderExpect = "Expect"
+
+// Expect modes.
+const (
+	ExpectModeAccept = "accept"
+	ExpectModeReject = "reject"
+	ExpectModeDelay  = "delay"
+)
+
+// DefaultEarlyHintsLink is the link early hints are sent with if none are given.
+const DefaultEarlyHintsLink = "</static/style.css>; rel=preload; as=style"
+
+// MaxInformationalDelay is the longest delay informational endpoints wait.
+const MaxInformationalDelay = time.Minute
+
+// Informational is a controller for endpoints that send informational (1xx) responses;
+// `103 Early Hints` and other 1xx responses before the final response, and control of `Expect: 100-continue`.
+type Informational struct{}
+
+// Register implements web.Controller.
+func (i Informational) Register(app *web.App) {
+	app.GET("/early-hints", i.earlyHints)
+	app.GET("/informational/:codes", i.informational)
+	app.POST("/expect", i.expect)
+	app.PUT("/expect", i.expect)
+}
+
+// earlyHints sends `103 Early Hints` with the `link` query parameters (or a default link)
+// and then the final response with the same links, optionally waiting `delay` between them.
+func (i Informational) earlyHints(r *web.Ctx) web.Result {
+	delay, err := informationalDelay(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	links := r.Request.URL.Query()["link"]
+	if len(links) == 0 {
+		links = []string{DefaultEarlyHintsLink}
+	}
+	header := r.Response.Header()
+	header[HeaderLink] = links
+	r.Response.WriteHeader(http.StatusEarlyHints)
+	time.Sleep(delay)
+	return web.Text.Result("early hints sent")
+}
+
+// informational sends a comma separated list of 1xx responses, e.g. `/informational/102,103`,
+// each with an `X-Informational` header numbering it, then the final response,
+// optionally waiting `delay` after each.
+//
+// `101 Switching Protocols` isn't informational in this sense (it ends the response) and isn't allowed.
+func (i Informational) informational(r *web.Ctx) web.Result {
+	delay, err := informationalDelay(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	value, err := r.RouteParam("codes")
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	var codes []int
+	for _, field := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || code < 100 || code > 199 || code == http.StatusSwitchingProtocols {
+			return web.Text.BadRequest(ex.New("invalid informational status; must be from 100 to 199, other than 101", ex.OptMessagef("status: %s", field)))
+		}
+		codes = append(codes, code)
+	}
+
+	header := r.Response.Header()
+	for index, code := range codes {
+		header.Set("X-Informational", strconv.Itoa(index+1))
+		r.Response.WriteHeader(code)
+		time.Sleep(delay)
+	}
+	header.Del("X-Informational")
+	return web.Text.Result("informational responses sent: " + value)
+}
+
+// ExpectResponse describes how a request's `Expect: 100-continue` was handled.
+type ExpectResponse struct {
+	Expect string `json:"expect,omitempty"`
+	Mode   string `json:"mode"`
+	// Delay is how long the server waited before asking for the body.
+	Delay string `json:"delay,omitempty"`
+	// BodySize is the size of the body the client sent.
+	BodySize int64 `json:"bodySize"`
+}
+
+// expect controls how `Expect: 100-continue` is handled, by the `mode` query parameter:
+//
+//	accept  reads the body straight away, so `100 Continue` is sent (the default)
+//	reject  responds `417 Expectation Failed` without reading the body
+//	delay   waits `delay` (one second by default) before reading the body
+//
+// net/http sends `100 Continue` when a handler first reads the body of a request that expects it.
+func (i Informational) expect(r *web.Ctx) web.Result {
+	response := ExpectResponse{
+		Expect: r.Request.Header.Get(HeaderExpect),
+		Mode:   r.Request.URL.Query().Get("mode"),
+	}
+	if response.Mode == "" {
+		response.Mode = ExpectModeAccept
+	}
+	switch response.Mode {
+	case ExpectModeAccept:
+	case ExpectModeReject:
+		return web.Text.Status(http.StatusExpectationFailed, "expectation failed")
+	case ExpectModeDelay:
+		delay := time.Second
+		if r.Request.URL.Query().Get("delay") != "" {
+			var err error
+			if delay, err = informationalDelay(r); err != nil {
+				return web.Text.BadRequest(err)
+			}
+		}
+		response.Delay = delay.String()
+		time.Sleep(delay)
+	default:
+		return web.Text.BadRequest(ex.New("invalid mode; must be `accept`, `reject` or `delay`", ex.OptMessagef("mode: %s", response.Mode)))
+	}
+
+	size, err := io.Copy(ioutil.Discard, r.Request.Body)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	response.BodySize = size
+	return Negotiated(r, FormatJSON, response)
+}
+
+// informationalDelay returns the `delay` query parameter, a duration (e.g. `500ms`), or zero.
+func informationalDelay(r *web.Ctx) (time.Duration, error) {
+	value := r.Request.URL.Query().Get("delay")
+	if value == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay < 0 || delay > MaxInformationalDelay {
+		return 0, ex.New("invalid delay; must be a duration up to a minute", ex.OptMessagef("delay: %s", value))
+	}
+	return delay, nil
+}
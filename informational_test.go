@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// informationalResponse is a 1xx response a client received.
+type informationalResponse struct {
+	StatusCode int
+	Header     textproto.MIMEHeader
+}
+
+// getInformational makes a request recording the 1xx responses before the final response, returning both and the body.
+func getInformational(t *testing.T, url string) ([]informationalResponse, *http.Response, string) {
+	t.Helper()
+	var informational []informationalResponse
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = append(informational, informationalResponse{StatusCode: code, Header: header})
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return informational, res, string(body)
+}
+
+func TestInformational(t *testing.T) {
+	app := web.New()
+	app.Register(Informational{})
+	addr := startServer(t, NewServer(app))
+
+	informational, res, body := getInformational(t, "http://"+addr+"/informational/102,103")
+	if len(informational) != 2 {
+		t.Fatalf("expected 2 informational responses, got %+v", informational)
+	}
+	for index, code := range []int{http.StatusProcessing, http.StatusEarlyHints} {
+		if informational[index].StatusCode != code || informational[index].Header.Get("X-Informational") != string(rune('1'+index)) {
+			t.Errorf("expected informational response %d to be %d, got %+v", index+1, code, informational[index])
+		}
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("X-Informational") != "" || body != "informational responses sent: 102,103" {
+		t.Errorf("unexpected final response %d %v: %q", res.StatusCode, res.Header, body)
+	}
+
+	for _, codes := range []string{"101", "99", "200", "103,abc"} {
+		informational, res, _ := getInformational(t, "http://"+addr+"/informational/"+codes)
+		if len(informational) != 0 || res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected a bad request without informational responses, got %d after %+v", codes, res.StatusCode, informational)
+		}
+	}
+}
+
+func TestInformationalEarlyHints(t *testing.T) {
+	app := web.New()
+	app.Register(Informational{})
+	addr := startServer(t, NewServer(app))
+
+	expected := []string{"</a.js>; rel=preload", "</b.css>; rel=preload"}
+	informational, res, _ := getInformational(t, "http://"+addr+"/early-hints?"+url.Values{"link": expected}.Encode())
+	if len(informational) != 1 || informational[0].StatusCode != http.StatusEarlyHints {
+		t.Fatalf("expected early hints, got %+v", informational)
+	}
+	if actual := informational[0].Header[HeaderLink]; strings.Join(actual, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected early hints links %q, got %q", expected, actual)
+	}
+	if actual := res.Header[HeaderLink]; res.StatusCode != http.StatusOK || strings.Join(actual, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected the final response to have links %q, got %d %q", expected, res.StatusCode, actual)
+	}
+
+	informational, _, _ = getInformational(t, "http://"+addr+"/early-hints")
+	if len(informational) != 1 || informational[0].Header.Get(HeaderLink) != DefaultEarlyHintsLink {
+		t.Errorf("expected early hints with the default link, got %+v", informational)
+	}
+}
+
+func TestInformationalExpect(t *testing.T) {
+	app := web.New()
+	app.Register(Informational{})
+	addr := startServer(t, NewServer(app))
+
+	testCases := [...]struct {
+		Mode       string
+		StatusCode int
+		Continue   bool
+	}{
+		{Mode: "", StatusCode: http.StatusOK, Continue: true},
+		{Mode: ExpectModeAccept, StatusCode: http.StatusOK, Continue: true},
+		{Mode: ExpectModeDelay + "&delay=10ms", StatusCode: http.StatusOK, Continue: true},
+		{Mode: ExpectModeReject, StatusCode: http.StatusExpectationFailed},
+		{Mode: "nope", StatusCode: http.StatusBadRequest},
+	}
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Minute}}
+	defer client.CloseIdleConnections()
+	for _, tc := range testCases {
+		var continued bool
+		trace := &httptrace.ClientTrace{Got100Continue: func() { continued = true }}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodPost, "http://"+addr+"/expect?mode="+tc.Mode, bytes.NewReader(make([]byte, 1024)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(HeaderExpect, "100-continue")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tc.StatusCode || continued != tc.Continue {
+			t.Errorf("%q: expected %d with continue %v, got %d with continue %v: %s", tc.Mode, tc.StatusCode, tc.Continue, res.StatusCode, continued, body)
+			continue
+		}
+		if tc.StatusCode != http.StatusOK {
+			continue
+		}
+		var response ExpectResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Expect != "100-continue" || response.BodySize != 1024 {
+			t.Errorf("%q: unexpected response %+v", tc.Mode, response)
+		}
+	}
+}
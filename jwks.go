@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/jwt"
+)
+
+// JWK key types.
+const (
+	JWKKeyTypeRSA = "RSA"
+	JWKKeyTypeEC  = "EC"
+	JWKKeyTypeOct = "oct"
+)
+
+// JWKS is a JSON web key set (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a JSON web key.
+// Only the fields for RSA, EC and symmetric (oct) keys are supported.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+
+	// oct
+	K string `json:"k,omitempty"`
+}
+
+// NewJWK returns the JWK for a public key; an `*rsa.PublicKey` or `*ecdsa.PublicKey`.
+func NewJWK(keyID, algorithm string, key interface{}) (JWK, error) {
+	switch typed := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   JWKKeyTypeRSA,
+			KeyID:     keyID,
+			Use:       "sig",
+			Algorithm: algorithm,
+			N:         jwt.EncodeSegment(typed.N.Bytes()),
+			E:         jwt.EncodeSegment(big.NewInt(int64(typed.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		// coordinates are left padded to the curve size.
+		size := (typed.Curve.Params().BitSize + 7) / 8
+		x, y := make([]byte, size), make([]byte, size)
+		typed.X.FillBytes(x)
+		typed.Y.FillBytes(y)
+		return JWK{
+			KeyType:   JWKKeyTypeEC,
+			KeyID:     keyID,
+			Use:       "sig",
+			Algorithm: algorithm,
+			Curve:     typed.Curve.Params().Name,
+			X:         jwt.EncodeSegment(x),
+			Y:         jwt.EncodeSegment(y),
+		}, nil
+	default:
+		return JWK{}, ex.New("unsupported public key type", ex.OptMessagef("type: %T", key))
+	}
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, suitable for use as a key id.
+func (k JWK) Thumbprint() string {
+	var members string
+	switch k.KeyType {
+	case JWKKeyTypeRSA:
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, k.E, k.KeyType, k.N)
+	case JWKKeyTypeEC:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Curve, k.KeyType, k.X, k.Y)
+	default:
+		members = fmt.Sprintf(`{"k":%q,"kty":%q}`, k.K, k.KeyType)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return jwt.EncodeSegment(sum[:])
+}
+
+// ReadJWKSFile reads a JWKS from a json file.
+func ReadJWKSFile(path string) (*JWKS, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ex.New(err)
+	}
+	var jwks JWKS
+	if err := json.Unmarshal(contents, &jwks); err != nil {
+		return nil, ex.New(err, ex.OptMessagef("path: %s", path))
+	}
+	return &jwks, nil
+}
+
+// Key returns the verification key for the JWK; an `*rsa.PublicKey`, `*ecdsa.PublicKey` or `[]byte`.
+func (k JWK) Key() (interface{}, error) {
+	switch k.KeyType {
+	case JWKKeyTypeRSA:
+		n, err := jwt.DecodeSegment(k.N)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s; invalid modulus", k.KeyID))
+		}
+		e, err := jwt.DecodeSegment(k.E)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s; invalid exponent", k.KeyID))
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case JWKKeyTypeEC:
+		curve, err := jwkCurve(k.Curve)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s", k.KeyID))
+		}
+		x, err := jwt.DecodeSegment(k.X)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s; invalid x coordinate", k.KeyID))
+		}
+		y, err := jwt.DecodeSegment(k.Y)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s; invalid y coordinate", k.KeyID))
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case JWKKeyTypeOct:
+		key, err := jwt.DecodeSegment(k.K)
+		if err != nil {
+			return nil, ex.New(err, ex.OptMessagef("kid: %s; invalid key", k.KeyID))
+		}
+		return key, nil
+	default:
+		return nil, ex.New("unsupported jwk key type", ex.OptMessagef("kid: %s; kty: %s", k.KeyID, k.KeyType))
+	}
+}
+
+func jwkCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, ex.New("unsupported jwk curve", ex.OptMessagef("crv: %s", name))
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/jwt"
+	"github.com/blend/go-sdk/web"
+)
+
+// JWTKey is a named key used to verify token signatures.
+type JWTKey struct {
+	// Name describes where the key came from, e.g. `hmac[0]` or `jwks:<kid>`.
+	Name string
+	// KeyID is the `kid` the key is published under, if any.
+	KeyID string
+	// Key is an `[]byte`, `*rsa.PublicKey` or `*ecdsa.PublicKey`.
+	Key interface{}
+}
+
+// Accepts returns if the key can verify tokens signed with a given algorithm.
+func (k JWTKey) Accepts(alg string) bool {
+	switch k.Key.(type) {
+	case []byte:
+		return strings.HasPrefix(alg, "HS")
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	default:
+		return false
+	}
+}
+
+// LoadJWTKeys loads the verification keys named by the config.
+func LoadJWTKeys(cfg Config) ([]JWTKey, error) {
+	var keys []JWTKey
+	for index, secret := range cfg.JWTHMACSecrets {
+		if secret == "" {
+			continue
+		}
+		keys = append(keys, JWTKey{Name: fmt.Sprintf("hmac[%d]", index), Key: []byte(secret)})
+	}
+
+	for _, path := range cfg.JWTPublicKeyFiles {
+		if path == "" {
+			continue
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, ex.New(err)
+		}
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(contents); err == nil {
+			keys = append(keys, JWTKey{Name: "pem:" + path, Key: key})
+			continue
+		}
+		key, err := jwt.ParseECPublicKeyFromPEM(contents)
+		if err != nil {
+			return nil, ex.New("invalid public key file; must be a PEM encoded RSA or ECDSA public key or certificate", ex.OptMessagef("path: %s", path), ex.OptInner(err))
+		}
+		keys = append(keys, JWTKey{Name: "pem:" + path, Key: key})
+	}
+
+	if cfg.JWKSFile != "" {
+		jwks, err := ReadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, jwk := range jwks.Keys {
+			key, err := jwk.Key()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, JWTKey{Name: "jwks:" + jwk.KeyID, KeyID: jwk.KeyID, Key: key})
+		}
+	}
+	return keys, nil
+}
+
+// JWTInspector is a controller that verifies bearer tokens and reports why they pass or fail.
+type JWTInspector struct {
+	Keys []JWTKey
+}
+
+// Register implements web.Controller.
+func (ji JWTInspector) Register(app *web.App) {
+	app.GET("/auth/jwt", ji.inspect)
+	app.POST("/auth/jwt", ji.inspect)
+}
+
+// JWTInspection is the result of inspecting a token.
+type JWTInspection struct {
+	Header         map[string]interface{} `json:"header,omitempty"`
+	Claims         jwt.MapClaims          `json:"claims,omitempty"`
+	Algorithm      string                 `json:"algorithm,omitempty"`
+	SignatureValid bool                   `json:"signatureValid"`
+	VerifiedBy     string                 `json:"verifiedBy,omitempty"`
+	KeysTried      []string               `json:"keysTried"`
+	IssuedAt       *time.Time             `json:"issuedAt,omitempty"`
+	NotBefore      *time.Time             `json:"notBefore,omitempty"`
+	ExpiresAt      *time.Time             `json:"expiresAt,omitempty"`
+	Expired        bool                   `json:"expired"`
+	ExpiresIn      string                 `json:"expiresIn,omitempty"`
+	Valid          bool                   `json:"valid"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+func (ji JWTInspector) inspect(r *web.Ctx) web.Result {
+	token, ok := BearerToken(r.Request)
+	if !ok {
+		return web.JSON.BadRequest(ex.New("missing `Authorization: Bearer` token"))
+	}
+	return Negotiated(r, FormatJSON, ji.Inspect(token))
+}
+
+// Inspect parses and verifies a raw token against each applicable key.
+func (ji JWTInspector) Inspect(raw string) JWTInspection {
+	inspection := JWTInspection{
+		KeysTried: []string{},
+	}
+
+	claims := jwt.MapClaims{}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(raw, claims)
+	if parsed != nil {
+		inspection.Header = parsed.Header
+		inspection.Claims = claims
+		if alg, ok := parsed.Header["alg"].(string); ok {
+			inspection.Algorithm = alg
+		}
+	}
+	if err != nil {
+		inspection.Error = fmt.Sprintf("%v", err)
+		return inspection
+	}
+
+	now := jwt.TimeFunc()
+	inspection.IssuedAt = claimTime(claims, "iat")
+	inspection.NotBefore = claimTime(claims, "nbf")
+	if inspection.ExpiresAt = claimTime(claims, "exp"); inspection.ExpiresAt != nil {
+		inspection.Expired = now.After(*inspection.ExpiresAt)
+		inspection.ExpiresIn = inspection.ExpiresAt.Sub(now).String()
+	}
+
+	// verify the signature on its own; the parser checks claims before signatures
+	// and would otherwise hide a bad signature behind an expired token.
+	signatureParser := jwt.Parser{SkipClaimsValidation: true}
+	var signatureErr error = ex.New(jwt.ErrValidation, ex.OptMessagef("no configured keys accept alg %q", inspection.Algorithm))
+	for _, key := range ji.candidateKeys(parsed) {
+		inspection.KeysTried = append(inspection.KeysTried, key.Name)
+		if _, signatureErr = signatureParser.ParseWithClaims(raw, jwt.MapClaims{}, staticKeyfunc(key.Key)); signatureErr == nil {
+			inspection.SignatureValid = true
+			inspection.VerifiedBy = key.Name
+			break
+		}
+	}
+	if !inspection.SignatureValid {
+		inspection.Error = fmt.Sprintf("%v", signatureErr)
+		return inspection
+	}
+
+	if err := claims.Valid(); err != nil {
+		inspection.Error = fmt.Sprintf("%v", ex.New(jwt.ErrValidation, ex.OptInner(err)))
+		return inspection
+	}
+	inspection.Valid = true
+	return inspection
+}
+
+// candidateKeys returns the keys that could have signed a token.
+// If the token names a `kid` we know, only that key is tried.
+func (ji JWTInspector) candidateKeys(token *jwt.Token) (output []JWTKey) {
+	alg, _ := token.Header["alg"].(string)
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		for _, key := range ji.Keys {
+			if key.KeyID == kid && key.Accepts(alg) {
+				return []JWTKey{key}
+			}
+		}
+	}
+	for _, key := range ji.Keys {
+		if key.Accepts(alg) {
+			output = append(output, key)
+		}
+	}
+	return
+}
+
+func staticKeyfunc(key interface{}) jwt.Keyfunc {
+	return func(_ *jwt.Token) (interface{}, error) {
+		return key, nil
+	}
+}
+
+// claimTime returns a numeric date claim as a time.
+func claimTime(claims jwt.MapClaims, name string) *time.Time {
+	var seconds int64
+	switch typed := claims[name].(type) {
+	case float64:
+		seconds = int64(typed)
+	case int64:
+		seconds = typed
+	default:
+		return nil
+	}
+	value := time.Unix(seconds, 0).UTC()
+	return &value
+}
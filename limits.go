@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/blend/go-sdk/env"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/logger"
+)
+
+const (
+	// EnvVarGOMAXPROCS is the runtime's own override for the number of procs.
+	EnvVarGOMAXPROCS = "GOMAXPROCS"
+	// EnvVarGOMEMLIMIT is the runtime's own override for the soft memory limit.
+	EnvVarGOMEMLIMIT = "GOMEMLIMIT"
+)
+
+// ApplyRuntimeLimits sets `GOMAXPROCS` and the GC soft memory limit from the cgroup
+// cpu quota and memory limit found at a given cgroup root.
+// The runtime's own `GOMAXPROCS` and `GOMEMLIMIT` env vars take precedence.
+func ApplyRuntimeLimits(cfg Config, log logger.Log, root string) {
+	cgroup, err := ReadCgroup(root)
+	if err != nil {
+		logger.MaybeWarningf(log, "runtime limits; cannot read cgroup: %v", err)
+		return
+	}
+
+	switch {
+	case cfg.AutoMaxProcsDisabled:
+		logger.MaybeInfof(log, "runtime limits; automatic GOMAXPROCS disabled, using %d", runtime.GOMAXPROCS(0))
+	case env.Env().Has(EnvVarGOMAXPROCS):
+		logger.MaybeInfof(log, "runtime limits; %s is set, using %d", EnvVarGOMAXPROCS, runtime.GOMAXPROCS(0))
+	case cgroup.CPULimit == 0:
+		logger.MaybeInfof(log, "runtime limits; no cgroup cpu quota, using GOMAXPROCS %d", runtime.GOMAXPROCS(0))
+	default:
+		procs := MaxProcsForCPULimit(cgroup.CPULimit)
+		previous := runtime.GOMAXPROCS(procs)
+		logger.MaybeInfof(log, "runtime limits; cgroup cpu limit %.3f, set GOMAXPROCS %d (was %d)", cgroup.CPULimit, procs, previous)
+	}
+
+	switch {
+	case cfg.AutoMemoryLimitDisabled:
+		logger.MaybeInfof(log, "runtime limits; automatic memory limit disabled")
+	case env.Env().Has(EnvVarGOMEMLIMIT):
+		logger.MaybeInfof(log, "runtime limits; %s is set, using %s", EnvVarGOMEMLIMIT, fileutil.FormatFileSize(debug.SetMemoryLimit(-1)))
+	case cgroup.MemoryLimit == 0:
+		logger.MaybeInfof(log, "runtime limits; no cgroup memory limit, leaving the GC memory limit unset")
+	default:
+		limit := int64(float64(cgroup.MemoryLimit) * cfg.MemoryLimitRatioOrDefault())
+		debug.SetMemoryLimit(limit)
+		logger.MaybeInfof(log, "runtime limits; cgroup memory limit %s, set GC memory limit %s", fileutil.FormatFileSize(cgroup.MemoryLimit), fileutil.FormatFileSize(limit))
+	}
+}
+
+// MaxProcsForCPULimit returns the GOMAXPROCS for a given cpu limit in cores.
+// Fractional limits round up, and the result is never less than one.
+func MaxProcsForCPULimit(cpuLimit float64) int {
+	procs := int(math.Ceil(cpuLimit))
+	if procs < 1 {
+		return 1
+	}
+	return procs
+}
@@ -1,12 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/blend/go-sdk/env"
 	"github.com/blend/go-sdk/ex"
 	"github.com/blend/go-sdk/graceful"
 	"github.com/blend/go-sdk/logger"
@@ -18,19 +16,69 @@ func main() {
 
 	appStart := time.Now()
 
-	app := web.New(web.OptConfigFromEnv(), web.OptLog(log))
+	var cfg Config
+	if err := cfg.Resolve(); err != nil {
+		logger.FatalExit(err)
+	}
+	ApplyRuntimeLimits(cfg, log, DefaultCgroupRoot)
+	DumpGoroutinesOnSignal(log)
+
+	jwtKeys, err := LoadJWTKeys(cfg)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	var oidc *OIDC
+	if cfg.OIDCConfigFile != "" {
+		oidcConfig, err := ReadOIDCConfigFile(cfg.OIDCConfigFile)
+		if err != nil {
+			logger.FatalExit(err)
+		}
+		if oidc, err = NewOIDC(*oidcConfig); err != nil {
+			logger.FatalExit(err)
+		}
+		jwtKeys = append(jwtKeys, oidc.VerificationKey())
+	}
+
+	webCfg, err := ResolveWebConfig(cfg, log)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	cors, err := NewCORS(cfg)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	clientIPs, err := NewClientIPResolver(cfg)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	proxyProtocol, err := NewProxyProtocol(cfg, log)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	rateLimit, err := NewRateLimit(cfg, jwtKeys, clientIPs)
+	if err != nil {
+		logger.FatalExit(err)
+	}
+
+	connections := NewConnTracker(cfg)
+
+	crash := &Crash{Config: cfg, Log: log}
+
+	app := web.New(web.OptConfig(webCfg), web.OptLog(ClientIPLog{Log: log, Resolver: clientIPs}), web.OptUse(crash.Gate), web.OptUse(rateLimit.Middleware), web.OptUse(cors.Middleware), web.OptUse(RestoreAcceptEncoding), web.OptUse(DecompressRequest(cfg, RawRoute)))
 	app.GET("/", func(r *web.Ctx) web.Result {
 		return web.Text.Result("echo")
 	})
+	app.Views.AddLiterals(ViewNegotiatedTemplate)
 	app.GET("/headers", func(r *web.Ctx) web.Result {
-		contents, err := json.Marshal(r.Request.Header)
-		if err != nil {
-			return r.Views.InternalError(err)
-		}
-		return web.Text.Result(string(contents))
+		return Negotiated(r, FormatText, r.Request.Header)
 	})
 	app.GET("/env", func(r *web.Ctx) web.Result {
-		return web.JSON.Result(env.Env().Vars())
+		return Negotiated(r, FormatJSON, EnvVarNames())
 	})
 	app.GET("/error", func(r *web.Ctx) web.Result {
 		return web.JSON.InternalError(ex.New("This is only a test", ex.OptMessagef("this is a message"), ex.OptInner(ex.New("inner exception"))))
@@ -69,7 +117,47 @@ func main() {
 		}
 	})
 
-	if err := graceful.Shutdown(app); err != nil {
+	app.Register(
+		crash,
+		Info{AppStart: appStart},
+		Debug{Config: cfg},
+		JWTInspector{Keys: jwtKeys},
+		NewAuthChallenge(cfg),
+		SessionDemo{Config: cfg},
+		Cookies{},
+		Redirects{Config: cfg},
+		Compression{Config: cfg},
+		Cache{LastModified: appStart},
+		Payloads{},
+		Static{Config: cfg},
+		Upload{Config: cfg},
+		Responses{},
+		Raw{Config: cfg},
+		Informational{},
+		Trailers{},
+		Malformed{Log: log},
+		connections,
+		clientIPs,
+		proxyProtocol,
+		cors,
+	)
+	if oidc != nil {
+		app.Register(oidc)
+	}
+	cors.RegisterPreflight(app)
+
+	var middleware []HTTPMiddleware
+	if !cfg.CompressionDisabled {
+		middleware = append(middleware, Compress(cfg))
+	}
+	if !cfg.ETagsDisabled {
+		middleware = append(middleware, ETags(cfg))
+	}
+	server := NewServer(app, middleware...)
+	server.ProxyProtocol = proxyProtocol
+	server.Connections = connections
+	server.RawMaxSize = cfg.RawMaxSizeOrDefault()
+	if err := graceful.Shutdown(server); err != nil {
 		logger.FatalExit(err)
 	}
 }
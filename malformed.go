@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+// Malformed response modes.
+const (
+	MalformedModeTruncated              = "truncated"
+	MalformedModeOverlong               = "overlong"
+	MalformedModeBadChunking            = "bad-chunking"
+	MalformedModeReset                  = "reset"
+	MalformedModeStall                  = "stall"
+	MalformedModeGarbageStatus          = "garbage-status"
+	MalformedModeDuplicateContentLength = "duplicate-content-length"
+)
+
+// MalformedModes are the malformed response modes.
+var MalformedModes = []string{
+	MalformedModeTruncated,
+	MalformedModeOverlong,
+	MalformedModeBadChunking,
+	MalformedModeReset,
+	MalformedModeStall,
+	MalformedModeGarbageStatus,
+	MalformedModeDuplicateContentLength,
+}
+
+const (
+	// DefaultMalformedStall is how long the stall mode waits before responding by default.
+	DefaultMalformedStall = 10 * time.Second
+	// DefaultSlowReadRate is the default rate, in bytes per second, slow reads read request bodies at.
+	DefaultSlowReadRate = 1024
+	// slowReadInterval is how often slow reads read.
+	slowReadInterval = 100 * time.Millisecond
+)
+
+// malformedBody is the body malformed responses send (or send some of).
+const malformedBody = "the quick brown fox jumps over the lazy dog\n"
+
+// Malformed is a controller for endpoints that break http on purpose, for testing how clients cope;
+// responses that don't match their framing, connections that reset or stall, and garbage instead
+// of a response. The connection is taken over to write them, since net/http won't, and closed after.
+//
+// It also has endpoints that read request bodies slowly, for testing clients that send them.
+type Malformed struct {
+	Log logger.Log
+}
+
+// Register implements web.Controller.
+func (m Malformed) Register(app *web.App) {
+	app.GET("/malformed/:mode", m.malformed)
+	app.POST("/slow-read", m.slowRead)
+	app.PUT("/slow-read", m.slowRead)
+}
+
+// malformed writes a malformed response, by mode:
+//
+//	truncated                 a body shorter than its `Content-Length`, then the connection closes
+//	overlong                  a body longer than its `Content-Length`
+//	bad-chunking              a chunked body with an invalid chunk size
+//	reset                     part of the body, then the connection resets (a TCP RST)
+//	stall                     nothing for `delay` (ten seconds by default), then a normal response
+//	garbage-status            bytes that aren't a status line
+//	duplicate-content-length  two conflicting `Content-Length` headers
+func (m Malformed) malformed(r *web.Ctx) web.Result {
+	mode, err := r.RouteParam("mode")
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	delay := DefaultMalformedStall
+	if r.Request.URL.Query().Get("delay") != "" {
+		if delay, err = informationalDelay(r); err != nil {
+			return web.Text.BadRequest(err)
+		}
+	}
+
+	var write func(net.Conn, *bufio.ReadWriter) error
+	switch mode {
+	case MalformedModeTruncated:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			writeMalformedHead(rw, "200 OK", web.HeaderContentLength+": "+strconv.Itoa(2*len(malformedBody)))
+			rw.WriteString(malformedBody)
+			return rw.Flush()
+		}
+	case MalformedModeOverlong:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			writeMalformedHead(rw, "200 OK", web.HeaderContentLength+": "+strconv.Itoa(len(malformedBody)/2))
+			rw.WriteString(malformedBody)
+			return rw.Flush()
+		}
+	case MalformedModeBadChunking:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			writeMalformedHead(rw, "200 OK", "Transfer-Encoding: chunked")
+			fmt.Fprintf(rw, "%x\r\n%s\r\n", len(malformedBody), malformedBody)
+			rw.WriteString("zz\r\nnot a chunk\r\n0\r\n\r\n")
+			return rw.Flush()
+		}
+	case MalformedModeReset:
+		write = func(conn net.Conn, rw *bufio.ReadWriter) error {
+			writeMalformedHead(rw, "200 OK", web.HeaderContentLength+": "+strconv.Itoa(2*len(malformedBody)))
+			rw.WriteString(malformedBody)
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+			tcpConn, ok := findTCPConn(conn)
+			if !ok {
+				return ex.New("the connection is not a tcp connection and can't be reset")
+			}
+			// with a zero linger, closing discards what's unsent and sends a RST.
+			return tcpConn.SetLinger(0)
+		}
+	case MalformedModeStall:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			time.Sleep(delay)
+			writeMalformedHead(rw, "200 OK", web.HeaderContentLength+": "+strconv.Itoa(len(malformedBody)))
+			rw.WriteString(malformedBody)
+			return rw.Flush()
+		}
+	case MalformedModeGarbageStatus:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			rw.WriteString("\x00\xff\xfeNOT/HTTP garbage \x1b[0m\r\n\r\n")
+			rw.WriteString(malformedBody)
+			return rw.Flush()
+		}
+	case MalformedModeDuplicateContentLength:
+		write = func(_ net.Conn, rw *bufio.ReadWriter) error {
+			writeMalformedHead(rw, "200 OK",
+				web.HeaderContentLength+": "+strconv.Itoa(len(malformedBody)),
+				web.HeaderContentLength+": "+strconv.Itoa(len(malformedBody)/2),
+			)
+			rw.WriteString(malformedBody)
+			return rw.Flush()
+		}
+	default:
+		return web.Text.BadRequest(ex.New("invalid mode; must be one of "+strings.Join(MalformedModes, ", "), ex.OptMessagef("mode: %s", mode)))
+	}
+
+	conn, rw, err := Hijack(r.Request)
+	if err != nil {
+		return web.Text.InternalError(err)
+	}
+	defer conn.Close()
+	logger.MaybeError(m.Log, write(conn, rw))
+	return nil
+}
+
+// writeMalformedHead writes a status line and headers, as given.
+func writeMalformedHead(rw *bufio.ReadWriter, status string, headers ...string) {
+	rw.WriteString("HTTP/1.1 " + status + "\r\n")
+	rw.WriteString(web.HeaderContentType + ": " + web.ContentTypeText + "\r\n")
+	for _, header := range headers {
+		rw.WriteString(header + "\r\n")
+	}
+	rw.WriteString(web.HeaderConnection + ": close\r\n\r\n")
+}
+
+// findTCPConn returns the tcp connection under a connection, if there is one.
+func findTCPConn(conn net.Conn) (*net.TCPConn, bool) {
+	for conn != nil {
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			return tcpConn, true
+		}
+		conn = unwrapConn(conn)
+	}
+	return nil, false
+}
+
+// SlowReadResponse describes a slow read.
+type SlowReadResponse struct {
+	// Rate is the rate the body was read at, in bytes per second.
+	Rate     int    `json:"rate"`
+	BodySize int64  `json:"bodySize"`
+	Elapsed  string `json:"elapsed"`
+}
+
+// slowRead reads the request body at `rate` bytes per second (1024 by default),
+// a tenth of a second's worth at a time, then describes it.
+func (m Malformed) slowRead(r *web.Ctx) web.Result {
+	rate := DefaultSlowReadRate
+	if value := r.Request.URL.Query().Get("rate"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 10 {
+			return web.Text.BadRequest(ex.New("invalid rate; must be an integer of at least 10 (bytes per second)", ex.OptMessagef("rate: %s", value)))
+		}
+		rate = parsed
+	}
+
+	start := time.Now()
+	response := SlowReadResponse{Rate: rate}
+	ticker := time.NewTicker(slowReadInterval)
+	defer ticker.Stop()
+	step := int64(rate) * int64(slowReadInterval) / int64(time.Second)
+	for {
+		read, err := io.CopyN(ioutil.Discard, r.Request.Body, step)
+		response.BodySize += read
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return web.Text.BadRequest(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+	response.Elapsed = time.Since(start).Round(time.Millisecond).String()
+	return Negotiated(r, FormatJSON, response)
+}
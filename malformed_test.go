@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// getMalformed gets a malformed response with the go client, returning the error it fails with, if any,
+// whether making the request or reading the body, and the body read.
+func getMalformed(t *testing.T, client *http.Client, url string) (*http.Response, []byte, error) {
+	t.Helper()
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return res, body, err
+}
+
+func TestMalformed(t *testing.T) {
+	app := web.New()
+	app.Register(Malformed{})
+	addr := startServer(t, NewServer(app))
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	testCases := [...]struct {
+		Mode  string
+		Check func(*http.Response, []byte, error) bool
+	}{
+		{Mode: MalformedModeTruncated, Check: func(res *http.Response, body []byte, err error) bool {
+			return errors.Is(err, io.ErrUnexpectedEOF) && string(body) == malformedBody
+		}},
+		{Mode: MalformedModeBadChunking, Check: func(res *http.Response, body []byte, err error) bool {
+			return err != nil && strings.Contains(err.Error(), "chunk") && string(body) == malformedBody
+		}},
+		{Mode: MalformedModeReset, Check: func(res *http.Response, body []byte, err error) bool {
+			return errors.Is(err, syscall.ECONNRESET)
+		}},
+		{Mode: MalformedModeGarbageStatus, Check: func(res *http.Response, body []byte, err error) bool {
+			return res == nil && err != nil && strings.Contains(err.Error(), "malformed HTTP")
+		}},
+		{Mode: MalformedModeDuplicateContentLength, Check: func(res *http.Response, body []byte, err error) bool {
+			return res == nil && err != nil && strings.Contains(err.Error(), "multiple Content-Length")
+		}},
+		{Mode: MalformedModeStall + "?delay=50ms", Check: func(res *http.Response, body []byte, err error) bool {
+			return err == nil && res.StatusCode == http.StatusOK && string(body) == malformedBody
+		}},
+		{Mode: "nope", Check: func(res *http.Response, body []byte, err error) bool {
+			return err == nil && res.StatusCode == http.StatusBadRequest
+		}},
+		{Mode: MalformedModeStall + "?delay=forever", Check: func(res *http.Response, body []byte, err error) bool {
+			return err == nil && res.StatusCode == http.StatusBadRequest
+		}},
+	}
+	for _, tc := range testCases {
+		res, body, err := getMalformed(t, client, "http://"+addr+"/malformed/"+tc.Mode)
+		if !tc.Check(res, body, err) {
+			t.Errorf("%s: unexpected response %q with error %v", tc.Mode, body, err)
+		}
+	}
+}
+
+func TestMalformedOverlong(t *testing.T) {
+	app := web.New()
+	app.Register(Malformed{})
+	addr := startServer(t, NewServer(app))
+
+	// the go client stops at the content length, so the rest of the body would be read as the next response.
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	res, body := connRequest(t, conn, reader, "/malformed/"+MalformedModeOverlong)
+	if res.StatusCode != http.StatusOK || string(body) != malformedBody[:len(malformedBody)/2] {
+		t.Fatalf("expected the body cut at its content length, got %d %q", res.StatusCode, body)
+	}
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != malformedBody[len(malformedBody)/2:] {
+		t.Errorf("expected the rest of the body after the response, got %q", rest)
+	}
+	if _, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rest)), nil); err == nil {
+		t.Error("expected the rest of the body not to read as a response")
+	}
+}
+
+func TestMalformedStallTimeout(t *testing.T) {
+	app := web.New()
+	app.Register(Malformed{})
+	addr := startServer(t, NewServer(app))
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+
+	_, _, err := getMalformed(t, client, "http://"+addr+"/malformed/"+MalformedModeStall+"?delay=500ms")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestSlowRead(t *testing.T) {
+	app := web.New()
+	app.Register(Malformed{})
+	addr := startServer(t, NewServer(app))
+
+	// at 1000 bytes per second, 250 bytes are read in three reads a tenth of a second apart.
+	res, err := http.Post("http://"+addr+"/slow-read?rate=1000", web.ContentTypeText, strings.NewReader(strings.Repeat("a", 250)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var response SlowReadResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	elapsed, err := time.ParseDuration(response.Elapsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Rate != 1000 || response.BodySize != 250 || elapsed < 2*slowReadInterval {
+		t.Errorf("unexpected response %+v", response)
+	}
+
+	res, err = http.Post("http://"+addr+"/slow-read?rate=5", web.ContentTypeText, strings.NewReader("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a rate under 10 to be a bad request, got %d", res.StatusCode)
+	}
+}
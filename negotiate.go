@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// Format is a response format content negotiation can pick.
+type Format string
+
+// Formats.
+const (
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml"
+	FormatYAML Format = "yaml"
+	FormatText Format = "text"
+	FormatHTML Format = "html"
+)
+
+// FormatMediaTypes are the media types each format is served for, the first of which is its canonical type.
+var FormatMediaTypes = map[Format][]string{
+	FormatJSON: {"application/json"},
+	FormatXML:  {"application/xml", "text/xml"},
+	FormatYAML: {"application/yaml", "application/x-yaml", "text/yaml"},
+	FormatText: {"text/plain"},
+	FormatHTML: {"text/html"},
+}
+
+// NegotiatedFormats are the formats negotiation chooses between, in order of preference when q-values tie.
+var NegotiatedFormats = []Format{FormatJSON, FormatXML, FormatYAML, FormatText, FormatHTML}
+
+// ViewNegotiated is the name of the view used for html responses.
+const ViewNegotiated = "negotiated"
+
+// ViewNegotiatedTemplate renders a value as indented json in a page.
+const ViewNegotiatedTemplate = `{{ define "negotiated" }}<html><head><title>echo</title></head><body><pre>{{ .ViewModel }}</pre></body></html>{{ end }}`
+
+// Negotiate picks the response format for a request; from `?format=` if set, otherwise the `Accept` header.
+// The default format is preferred when it ties with others, and is used when there is no `Accept` header.
+// It returns false if no format is acceptable.
+func Negotiate(r *web.Ctx, defaultFormat Format) (Format, bool) {
+	if format := Format(strings.ToLower(r.Request.URL.Query().Get("format"))); format != "" {
+		_, ok := FormatMediaTypes[format]
+		return format, ok
+	}
+	addVary(r.Response.Header(), "Accept")
+
+	accept := r.Request.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return defaultFormat, true
+	}
+
+	ranges := parseAcceptRanges(accept)
+	var best Format
+	var bestQuality float64
+	for _, format := range append([]Format{defaultFormat}, NegotiatedFormats...) {
+		for _, mediaType := range FormatMediaTypes[format] {
+			if quality := acceptQuality(ranges, mediaType); quality > bestQuality {
+				best, bestQuality = format, quality
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// Negotiated returns a result rendering a value in the negotiated format, or a 406 if none is acceptable.
+func Negotiated(r *web.Ctx, defaultFormat Format, value interface{}) web.Result {
+	return NegotiatedStatus(r, defaultFormat, http.StatusOK, value)
+}
+
+// NegotiatedStatus returns a result with a given status code rendering a value in the negotiated format.
+func NegotiatedStatus(r *web.Ctx, defaultFormat Format, statusCode int, value interface{}) web.Result {
+	format, ok := Negotiate(r, defaultFormat)
+	if !ok {
+		var available []string
+		for _, format := range NegotiatedFormats {
+			available = append(available, FormatMediaTypes[format][0])
+		}
+		return web.Text.Status(http.StatusNotAcceptable, fmt.Sprintf("Not Acceptable; available formats are %s", strings.Join(available, ", ")))
+	}
+
+	switch format {
+	case FormatXML:
+		return &web.XMLResult{StatusCode: statusCode, Response: XMLValue{Value: value}}
+	case FormatYAML:
+		normalized, err := jsonNormalize(value)
+		if err != nil {
+			return web.JSON.InternalError(err)
+		}
+		return YAML.Status(statusCode, normalized)
+	case FormatText:
+		if text, ok := value.(string); ok {
+			return web.Text.Status(statusCode, text)
+		}
+		contents, err := json.Marshal(value)
+		if err != nil {
+			return web.Text.InternalError(err)
+		}
+		return web.Text.Status(statusCode, string(contents))
+	case FormatHTML:
+		contents, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return r.Views.InternalError(err)
+		}
+		return r.Views.ViewStatus(statusCode, ViewNegotiated, string(contents))
+	default:
+		return web.JSON.Status(statusCode, value)
+	}
+}
+
+// acceptRange is a media range from an `Accept` header.
+type acceptRange struct {
+	MediaType string
+	Quality   float64
+}
+
+// parseAcceptRanges parses an `Accept` header, most specific ranges first.
+func parseAcceptRanges(accept string) (output []acceptRange) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, quality := parseQualityValue(part)
+		if mediaType != "" {
+			output = append(output, acceptRange{MediaType: mediaType, Quality: quality})
+		}
+	}
+	specificity := func(mediaType string) int {
+		switch {
+		case mediaType == "*/*":
+			return 0
+		case strings.HasSuffix(mediaType, "/*"):
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return specificity(output[i].MediaType) > specificity(output[j].MediaType)
+	})
+	return
+}
+
+// acceptQuality returns the quality of the most specific range matching a media type, or zero.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	for _, candidate := range ranges {
+		if candidate.MediaType == mediaType || candidate.MediaType == "*/*" ||
+			(strings.HasSuffix(candidate.MediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(candidate.MediaType, "*"))) {
+			return candidate.Quality
+		}
+	}
+	return 0
+}
+
+// jsonNormalize converts a value to the generic maps, slices and scalars it marshals to as json,
+// so other formats use the same field names.
+func jsonNormalize(value interface{}) (output interface{}, err error) {
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &output)
+	return
+}
+
+// XMLValue marshals any json-able value as xml; objects become elements named by their keys
+// and arrays become repeated `item` elements, all under a `response` root.
+type XMLValue struct {
+	Value interface{}
+}
+
+var xmlInvalidNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// MarshalXML implements xml.Marshaler.
+func (xv XMLValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	normalized, err := jsonNormalize(xv.Value)
+	if err != nil {
+		return err
+	}
+	return xmlEncode(e, "response", normalized)
+}
+
+func xmlEncode(e *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlElementName(name)}}
+	if start.Name.Local != name {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}}
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := xmlEncode(e, key, typed[key]); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case []interface{}:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range typed {
+			if err := xmlEncode(e, "item", item); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case nil:
+		return e.EncodeElement("", start)
+	default:
+		return e.EncodeElement(fmt.Sprint(typed), start)
+	}
+}
+
+// xmlElementName returns a valid element name for a key; keys that aren't valid names
+// are written as `entry` elements with the key in a `name` attribute.
+func xmlElementName(key string) string {
+	if key == "" || xmlInvalidNameCharacters.MatchString(key) || strings.IndexAny(key[:1], "0123456789.-") == 0 || strings.HasPrefix(strings.ToLower(key), "xml") {
+		return "entry"
+	}
+	return key
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/jwt"
+	"github.com/blend/go-sdk/web"
+)
+
+// OAuth2 grant types.
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// PKCE code challenge methods.
+const (
+	CodeChallengeMethodPlain = "plain"
+	CodeChallengeMethodS256  = "S256"
+)
+
+// OAuth2 error codes (RFC 6749 section 5.2).
+const (
+	OAuthErrorInvalidRequest       = "invalid_request"
+	OAuthErrorInvalidClient        = "invalid_client"
+	OAuthErrorInvalidGrant         = "invalid_grant"
+	OAuthErrorUnauthorizedClient   = "unauthorized_client"
+	OAuthErrorUnsupportedGrantType = "unsupported_grant_type"
+	OAuthErrorInvalidToken         = "invalid_token"
+)
+
+// OIDCViewLogin is the name of the login page view.
+const OIDCViewLogin = "oidc_login"
+
+// OIDCViewLoginTemplate is the auto-approve login page; each user is a button that approves the request.
+const OIDCViewLoginTemplate = `{{ define "oidc_login" }}<html><head><title>echo; sign in</title><style>body { font-family: sans-serif; text-align: center; } button { margin: 4px; min-width: 240px; }</style></head>
+<body><h4>Sign in to {{ .ViewModel.ClientID }}</h4>
+<form method="POST" action="/authorize">
+{{ range $key, $values := .ViewModel.Params }}{{ range $values }}<input type="hidden" name="{{ $key }}" value="{{ . }}"/>{{ end }}{{ end }}
+{{ range .ViewModel.Users }}<div><button type="submit" name="login" value="{{ .Subject }}">{{ .Subject }}{{ if .Email }} &lt;{{ .Email }}&gt;{{ end }}</button></div>{{ end }}
+</form></body></html>{{ end }}`
+
+// NewOIDC returns a new mock OpenID Connect provider from a config.
+// It loads the signing key or generates one if none is configured.
+func NewOIDC(cfg OIDCConfig) (*OIDC, error) {
+	method := jwt.GetSigningMethod(cfg.SigningAlgorithmOrDefault())
+	if method == nil || strings.HasPrefix(method.Alg(), "HS") {
+		return nil, ex.New("invalid oidc signing algorithm; must be an RS* or ES* algorithm", ex.OptMessagef("alg: %s", cfg.SigningAlgorithmOrDefault()))
+	}
+	signingKey, err := oidcSigningKey(cfg, method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	publicKey := signingKey.(crypto.Signer).Public()
+	jwk, err := NewJWK("", method.Alg(), publicKey)
+	if err != nil {
+		return nil, err
+	}
+	jwk.KeyID = jwk.Thumbprint()
+
+	return &OIDC{
+		Config:        cfg,
+		Method:        method,
+		SigningKey:    signingKey,
+		PublicKey:     publicKey,
+		JWK:           jwk,
+		codes:         map[string]*oidcGrant{},
+		refreshTokens: map[string]*oidcGrant{},
+	}, nil
+}
+
+// OIDC is a controller that acts as a mock OpenID Connect identity provider.
+type OIDC struct {
+	Config     OIDCConfig
+	BaseURL    string
+	Method     jwt.SigningMethod
+	SigningKey interface{}
+	PublicKey  interface{}
+	JWK        JWK
+
+	sync.Mutex
+	codes         map[string]*oidcGrant
+	refreshTokens map[string]*oidcGrant
+	lastSweep     time.Time
+}
+
+// sweep forgets expired codes and refresh tokens, at most once a code lifetime.
+// Codes and refresh tokens that are never exchanged would otherwise be kept forever.
+func (o *OIDC) sweep(now time.Time) {
+	if now.Sub(o.lastSweep) < DefaultOIDCCodeTTL {
+		return
+	}
+	o.lastSweep = now
+	for code, grant := range o.codes {
+		if now.After(grant.Expires) {
+			delete(o.codes, code)
+		}
+	}
+	for refreshToken, grant := range o.refreshTokens {
+		if now.After(grant.Expires) {
+			delete(o.refreshTokens, refreshToken)
+		}
+	}
+}
+
+// oidcGrant is the state behind an authorization code or refresh token.
+type oidcGrant struct {
+	ClientID            string
+	Subject             string
+	Scope               string
+	Nonce               string
+	RedirectURI         string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	AuthTime            time.Time
+	Expires             time.Time
+}
+
+// Register implements web.Controller.
+func (o *OIDC) Register(app *web.App) {
+	if o.BaseURL == "" {
+		o.BaseURL = app.Config.BaseURL
+	}
+	app.Views.AddLiterals(OIDCViewLoginTemplate)
+
+	app.GET("/.well-known/openid-configuration", o.discovery)
+	app.GET("/.well-known/jwks.json", o.jwks)
+	app.GET("/authorize", o.authorize)
+	app.POST("/authorize", o.authorize)
+	app.POST("/token", o.token)
+	app.GET("/userinfo", o.userinfo)
+	app.POST("/userinfo", o.userinfo)
+}
+
+// VerificationKey returns the provider's public key for token verification elsewhere (e.g. `/auth/jwt`).
+func (o *OIDC) VerificationKey() JWTKey {
+	return JWTKey{Name: "oidc:" + o.JWK.KeyID, KeyID: o.JWK.KeyID, Key: o.PublicKey}
+}
+
+// Issuer returns the issuer for a request.
+func (o *OIDC) Issuer(r *web.Ctx) string {
+	if o.Config.Issuer != "" {
+		return strings.TrimSuffix(o.Config.Issuer, "/")
+	}
+	if o.BaseURL != "" {
+		return strings.TrimSuffix(o.BaseURL, "/")
+	}
+	return RequestBaseURL(r.Request)
+}
+
+//
+// routes
+//
+
+func (o *OIDC) discovery(r *web.Ctx) web.Result {
+	issuer := o.Issuer(r)
+	return web.JSON.Result(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials, GrantTypeRefreshToken},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{o.Method.Alg()},
+		"scopes_supported":                      []string{"openid", "profile", "email", "offline_access"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{CodeChallengeMethodPlain, CodeChallengeMethodS256},
+		"claims_supported":                      []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "name", "email", "email_verified"},
+	})
+}
+
+func (o *OIDC) jwks(r *web.Ctx) web.Result {
+	return web.JSON.Result(JWKS{Keys: []JWK{o.JWK}})
+}
+
+// authorize validates an authorization request, then either renders the login page
+// or, when a user is chosen (the login form or `login_hint`), approves it and redirects back with a code.
+func (o *OIDC) authorize(r *web.Ctx) web.Result {
+	params := r.Request.URL.Query()
+	if r.Request.Method == http.MethodPost {
+		if err := r.Request.ParseForm(); err != nil {
+			return web.Text.BadRequest(err)
+		}
+		params = r.Request.PostForm
+	}
+
+	// errors before the redirect uri is validated must not redirect.
+	client, ok := o.Config.Client(params.Get("client_id"))
+	if !ok {
+		return web.Text.BadRequest(ex.New("unknown client_id", ex.OptMessagef("client_id: %s", params.Get("client_id"))))
+	}
+	redirectURI := params.Get("redirect_uri")
+	if !client.AllowsRedirectURI(redirectURI) {
+		return web.Text.BadRequest(ex.New("redirect_uri is not registered for the client", ex.OptMessagef("redirect_uri: %s", redirectURI)))
+	}
+
+	state := params.Get("state")
+	if params.Get("response_type") != "code" {
+		return o.authorizeError(redirectURI, state, "unsupported_response_type", "only the code response type is supported")
+	}
+	challenge, challengeMethod := params.Get("code_challenge"), params.Get("code_challenge_method")
+	if challenge == "" && client.IsPublic() {
+		return o.authorizeError(redirectURI, state, OAuthErrorInvalidRequest, "public clients must use pkce")
+	}
+	if challenge != "" {
+		if challengeMethod == "" {
+			challengeMethod = CodeChallengeMethodPlain
+		}
+		if challengeMethod != CodeChallengeMethodPlain && challengeMethod != CodeChallengeMethodS256 {
+			return o.authorizeError(redirectURI, state, OAuthErrorInvalidRequest, "unsupported code_challenge_method")
+		}
+	}
+
+	subject := params.Get("login")
+	if subject == "" {
+		subject = params.Get("login_hint")
+	}
+	if subject == "" {
+		return r.Views.View(OIDCViewLogin, map[string]interface{}{
+			"ClientID": client.ClientID,
+			"Params":   params,
+			"Users":    o.Config.Users,
+		})
+	}
+	if _, ok := o.Config.User(subject); !ok {
+		return o.authorizeError(redirectURI, state, "access_denied", "unknown user")
+	}
+
+	code := web.NewSessionID()
+	o.Lock()
+	o.sweep(time.Now().UTC())
+	o.codes[code] = &oidcGrant{
+		ClientID:            client.ClientID,
+		Subject:             subject,
+		Scope:               params.Get("scope"),
+		Nonce:               params.Get("nonce"),
+		RedirectURI:         redirectURI,
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: challengeMethod,
+		AuthTime:            time.Now().UTC(),
+		Expires:             time.Now().UTC().Add(DefaultOIDCCodeTTL),
+	}
+	o.Unlock()
+
+	query := url.Values{"code": []string{code}}
+	if state != "" {
+		query.Set("state", state)
+	}
+	return web.RedirectWithMethod(http.MethodGet, appendQuery(redirectURI, query))
+}
+
+func (o *OIDC) token(r *web.Ctx) web.Result {
+	r.Response.Header().Set(web.HeaderCacheControl, "no-store")
+	if err := r.Request.ParseForm(); err != nil {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidRequest, err.Error())
+	}
+	form := r.Request.PostForm
+
+	client, errResult := o.authenticateClient(r, form)
+	if errResult != nil {
+		return errResult
+	}
+
+	switch grantType := form.Get("grant_type"); grantType {
+	case GrantTypeAuthorizationCode:
+		return o.tokenAuthorizationCode(r, client, form)
+	case GrantTypeClientCredentials:
+		return o.tokenClientCredentials(r, client, form)
+	case GrantTypeRefreshToken:
+		return o.tokenRefreshToken(r, client, form)
+	default:
+		return oauthError(http.StatusBadRequest, OAuthErrorUnsupportedGrantType, fmt.Sprintf("unsupported grant_type %q", grantType))
+	}
+}
+
+func (o *OIDC) tokenAuthorizationCode(r *web.Ctx, client OIDCClient, form url.Values) web.Result {
+	code := form.Get("code")
+	o.Lock()
+	grant, ok := o.codes[code]
+	delete(o.codes, code) // codes are single use, even if the exchange fails.
+	o.Unlock()
+
+	if !ok || time.Now().UTC().After(grant.Expires) {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "unknown or expired code")
+	}
+	if grant.ClientID != client.ClientID {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "code was issued to another client")
+	}
+	if grant.RedirectURI != form.Get("redirect_uri") {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if grant.CodeChallenge != "" {
+		if !verifyCodeChallenge(grant.CodeChallenge, grant.CodeChallengeMethod, form.Get("code_verifier")) {
+			return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "code_verifier does not match the code_challenge")
+		}
+	} else if form.Get("code_verifier") != "" {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "code_verifier sent without a code_challenge")
+	}
+	return o.issueTokens(r, grant)
+}
+
+func (o *OIDC) tokenClientCredentials(r *web.Ctx, client OIDCClient, form url.Values) web.Result {
+	if client.IsPublic() {
+		return oauthError(http.StatusBadRequest, OAuthErrorUnauthorizedClient, "public clients cannot use the client_credentials grant")
+	}
+	grant := &oidcGrant{
+		ClientID: client.ClientID,
+		Subject:  client.ClientID,
+		Scope:    form.Get("scope"),
+		AuthTime: time.Now().UTC(),
+	}
+	accessToken, err := o.accessToken(r, grant)
+	if err != nil {
+		return web.JSON.InternalError(err)
+	}
+	return web.JSON.Result(map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(o.Config.AccessTokenTTLOrDefault() / time.Second),
+		"scope":        grant.Scope,
+	})
+}
+
+func (o *OIDC) tokenRefreshToken(r *web.Ctx, client OIDCClient, form url.Values) web.Result {
+	refreshToken := form.Get("refresh_token")
+	o.Lock()
+	grant, ok := o.refreshTokens[refreshToken]
+	delete(o.refreshTokens, refreshToken) // refresh tokens are rotated on use.
+	o.Unlock()
+
+	if !ok || time.Now().UTC().After(grant.Expires) {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "unknown or expired refresh_token")
+	}
+	if grant.ClientID != client.ClientID {
+		return oauthError(http.StatusBadRequest, OAuthErrorInvalidGrant, "refresh_token was issued to another client")
+	}
+	if scope := form.Get("scope"); scope != "" {
+		if !scopeIsSubset(scope, grant.Scope) {
+			return oauthError(http.StatusBadRequest, "invalid_scope", "requested scope exceeds the original grant")
+		}
+		narrowed := *grant
+		narrowed.Scope = scope
+		grant = &narrowed
+	}
+	grant.Nonce = "" // the nonce only applies to the original id token.
+	return o.issueTokens(r, grant)
+}
+
+func (o *OIDC) userinfo(r *web.Ctx) web.Result {
+	raw, ok := BearerToken(r.Request)
+	if !ok {
+		r.Response.Header().Set("WWW-Authenticate", `Bearer realm="echo"`)
+		return oauthError(http.StatusUnauthorized, OAuthErrorInvalidToken, "missing bearer token")
+	}
+	claims := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: []string{o.Method.Alg()}}
+	if _, err := parser.ParseWithClaims(raw, claims, staticKeyfunc(o.PublicKey)); err != nil {
+		r.Response.Header().Set("WWW-Authenticate", `Bearer realm="echo", error="invalid_token"`)
+		return oauthError(http.StatusUnauthorized, OAuthErrorInvalidToken, fmt.Sprintf("%v", err))
+	}
+	subject, _ := claims["sub"].(string)
+	user, ok := o.Config.User(subject)
+	if !ok {
+		return oauthError(http.StatusUnauthorized, OAuthErrorInvalidToken, "token subject is not a user")
+	}
+	scope, _ := claims["scope"].(string)
+	output := jwt.MapClaims{"sub": user.Subject}
+	o.userClaims(output, user, scope)
+	return web.JSON.Result(output)
+}
+
+//
+// helpers
+//
+
+// authenticateClient identifies the client by http basic auth, form credentials, or (public clients) the client id alone.
+func (o *OIDC) authenticateClient(r *web.Ctx, form url.Values) (OIDCClient, web.Result) {
+	clientID, clientSecret, hasBasic := r.Request.BasicAuth()
+	if hasBasic {
+		// basic credentials are form encoded before being base64 encoded (RFC 6749 section 2.3.1).
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = form.Get("client_id"), form.Get("client_secret")
+	}
+
+	client, ok := o.Config.Client(clientID)
+	if !ok {
+		return client, oauthError(http.StatusUnauthorized, OAuthErrorInvalidClient, "unknown client")
+	}
+	if client.IsPublic() {
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(clientSecret), []byte(client.ClientSecret)) != 1 {
+		if hasBasic {
+			r.Response.Header().Set("WWW-Authenticate", `Basic realm="echo"`)
+		}
+		return client, oauthError(http.StatusUnauthorized, OAuthErrorInvalidClient, "invalid client credentials")
+	}
+	return client, nil
+}
+
+// issueTokens issues an access token, an id token if the `openid` scope was granted, and a refresh token.
+// Tokens issued from a refresh token keep the `auth_time` of the original login.
+func (o *OIDC) issueTokens(r *web.Ctx, grant *oidcGrant) web.Result {
+	accessToken, err := o.accessToken(r, grant)
+	if err != nil {
+		return web.JSON.InternalError(err)
+	}
+	response := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(o.Config.AccessTokenTTLOrDefault() / time.Second),
+		"scope":        grant.Scope,
+	}
+	if hasScope(grant.Scope, "openid") {
+		idToken, err := o.idToken(r, grant)
+		if err != nil {
+			return web.JSON.InternalError(err)
+		}
+		response["id_token"] = idToken
+	}
+
+	refreshToken := web.NewSessionID()
+	refreshGrant := *grant
+	refreshGrant.Expires = time.Now().UTC().Add(o.Config.RefreshTokenTTLOrDefault())
+	o.Lock()
+	o.sweep(time.Now().UTC())
+	o.refreshTokens[refreshToken] = &refreshGrant
+	o.Unlock()
+	response["refresh_token"] = refreshToken
+
+	return web.JSON.Result(response)
+}
+
+func (o *OIDC) accessToken(r *web.Ctx, grant *oidcGrant) (string, error) {
+	now := time.Now().UTC()
+	claims := jwt.MapClaims{
+		"iss":       o.Issuer(r),
+		"sub":       grant.Subject,
+		"aud":       grant.ClientID,
+		"client_id": grant.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(o.Config.AccessTokenTTLOrDefault()).Unix(),
+		"jti":       web.NewRequestID(),
+	}
+	if grant.Scope != "" {
+		claims["scope"] = grant.Scope
+	}
+	return o.sign(claims)
+}
+
+func (o *OIDC) idToken(r *web.Ctx, grant *oidcGrant) (string, error) {
+	now := time.Now().UTC()
+	claims := jwt.MapClaims{
+		"iss":       o.Issuer(r),
+		"sub":       grant.Subject,
+		"aud":       grant.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(o.Config.IDTokenTTLOrDefault()).Unix(),
+		"auth_time": grant.AuthTime.Unix(),
+	}
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	if user, ok := o.Config.User(grant.Subject); ok {
+		o.userClaims(claims, user, grant.Scope)
+	}
+	return o.sign(claims)
+}
+
+// userClaims adds the claims for a user permitted by the granted scope.
+// Custom claims are always included.
+func (o *OIDC) userClaims(claims jwt.MapClaims, user OIDCUser, scope string) {
+	if hasScope(scope, "profile") && user.Name != "" {
+		claims["name"] = user.Name
+	}
+	if hasScope(scope, "email") && user.Email != "" {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	for key, value := range user.Claims {
+		if _, reserved := claims[key]; !reserved {
+			claims[key] = normalizeYAML(value)
+		}
+	}
+}
+
+func (o *OIDC) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(o.Method, claims)
+	token.Header["kid"] = o.JWK.KeyID
+	return token.SignedString(o.SigningKey)
+}
+
+func (o *OIDC) authorizeError(redirectURI, state, code, description string) web.Result {
+	query := url.Values{
+		"error":             []string{code},
+		"error_description": []string{description},
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	return web.RedirectWithMethod(http.MethodGet, appendQuery(redirectURI, query))
+}
+
+// oauthError returns an RFC 6749 error response.
+func oauthError(statusCode int, code, description string) web.Result {
+	return web.JSON.Status(statusCode, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// oidcSigningKey reads the configured private key, or generates one for the algorithm.
+func oidcSigningKey(cfg OIDCConfig, alg string) (interface{}, error) {
+	isRSA := strings.HasPrefix(alg, "RS")
+	if cfg.SigningKeyFile != "" {
+		contents, err := ioutil.ReadFile(cfg.SigningKeyFile)
+		if err != nil {
+			return nil, ex.New(err)
+		}
+		if isRSA {
+			return jwt.ParseRSAPrivateKeyFromPEM(contents)
+		}
+		return jwt.ParseECPrivateKeyFromPEM(contents)
+	}
+	if isRSA {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	switch alg {
+	case jwt.SigningMethodNameES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwt.SigningMethodNameES512:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	default:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against the challenge (RFC 7636 section 4.6).
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	if method == CodeChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = jwt.EncodeSegment(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+func hasScope(scope, name string) bool {
+	for _, granted := range strings.Fields(scope) {
+		if granted == name {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeIsSubset(requested, granted string) bool {
+	for _, scope := range strings.Fields(requested) {
+		if !hasScope(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendQuery adds query values to a url that may already have a query.
+func appendQuery(rawURL string, query url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + query.Encode()
+	}
+	return rawURL + "?" + query.Encode()
+}
+
+// normalizeYAML converts the `map[interface{}]interface{}` values yaml produces
+// for nested maps into `map[string]interface{}` so they can be marshalled as json.
+func normalizeYAML(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, inner := range typed {
+			output[fmt.Sprint(key)] = normalizeYAML(inner)
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(typed))
+		for index, inner := range typed {
+			output[index] = normalizeYAML(inner)
+		}
+		return output
+	default:
+		return value
+	}
+}
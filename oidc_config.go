@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/jwt"
+	"github.com/blend/go-sdk/yaml"
+)
+
+// OIDC defaults.
+const (
+	DefaultOIDCSigningAlgorithm = jwt.SigningMethodNameRS256
+	DefaultOIDCAccessTokenTTL   = time.Hour
+	DefaultOIDCIDTokenTTL       = time.Hour
+	DefaultOIDCRefreshTokenTTL  = 24 * time.Hour
+	DefaultOIDCCodeTTL          = time.Minute
+)
+
+// OIDCConfig configures the mock OpenID Connect provider.
+type OIDCConfig struct {
+	// Issuer is the `iss` of issued tokens.
+	// If unset it is derived from the base url or the request host.
+	Issuer string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// SigningAlgorithm is `RS256` or `ES256`.
+	SigningAlgorithm string `json:"signingAlgorithm,omitempty" yaml:"signingAlgorithm,omitempty"`
+	// SigningKeyFile is a PEM encoded private key to sign tokens with.
+	// If unset a key is generated at startup.
+	SigningKeyFile  string        `json:"signingKeyFile,omitempty" yaml:"signingKeyFile,omitempty"`
+	AccessTokenTTL  time.Duration `json:"accessTokenTTL,omitempty" yaml:"accessTokenTTL,omitempty"`
+	IDTokenTTL      time.Duration `json:"idTokenTTL,omitempty" yaml:"idTokenTTL,omitempty"`
+	RefreshTokenTTL time.Duration `json:"refreshTokenTTL,omitempty" yaml:"refreshTokenTTL,omitempty"`
+
+	Users   []OIDCUser   `json:"users,omitempty" yaml:"users,omitempty"`
+	Clients []OIDCClient `json:"clients,omitempty" yaml:"clients,omitempty"`
+}
+
+// OIDCUser is a user that can log in to the mock provider.
+type OIDCUser struct {
+	Subject       string                 `json:"subject" yaml:"subject"`
+	Name          string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Email         string                 `json:"email,omitempty" yaml:"email,omitempty"`
+	EmailVerified bool                   `json:"emailVerified,omitempty" yaml:"emailVerified,omitempty"`
+	Claims        map[string]interface{} `json:"claims,omitempty" yaml:"claims,omitempty"`
+}
+
+// OIDCClient is a relying party registered with the mock provider.
+// Clients without a secret are public clients and must use PKCE.
+type OIDCClient struct {
+	ClientID     string   `json:"clientID" yaml:"clientID"`
+	ClientSecret string   `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	RedirectURIs []string `json:"redirectURIs,omitempty" yaml:"redirectURIs,omitempty"`
+}
+
+// ReadOIDCConfigFile reads the provider config from a yaml file.
+func ReadOIDCConfigFile(path string) (*OIDCConfig, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ex.New(err)
+	}
+	var cfg OIDCConfig
+	if err := yaml.UnmarshalStrict(contents, &cfg); err != nil {
+		return nil, ex.New(err, ex.OptMessagef("path: %s", path))
+	}
+	for _, client := range cfg.Clients {
+		if client.ClientID == "" {
+			return nil, ex.New("oidc client is missing a client id", ex.OptMessagef("path: %s", path))
+		}
+	}
+	for _, user := range cfg.Users {
+		if user.Subject == "" {
+			return nil, ex.New("oidc user is missing a subject", ex.OptMessagef("path: %s", path))
+		}
+	}
+	return &cfg, nil
+}
+
+// SigningAlgorithmOrDefault returns the signing algorithm or a default.
+func (c OIDCConfig) SigningAlgorithmOrDefault() string {
+	if c.SigningAlgorithm != "" {
+		return c.SigningAlgorithm
+	}
+	return DefaultOIDCSigningAlgorithm
+}
+
+// AccessTokenTTLOrDefault returns the access token lifetime or a default.
+func (c OIDCConfig) AccessTokenTTLOrDefault() time.Duration {
+	if c.AccessTokenTTL > 0 {
+		return c.AccessTokenTTL
+	}
+	return DefaultOIDCAccessTokenTTL
+}
+
+// IDTokenTTLOrDefault returns the id token lifetime or a default.
+func (c OIDCConfig) IDTokenTTLOrDefault() time.Duration {
+	if c.IDTokenTTL > 0 {
+		return c.IDTokenTTL
+	}
+	return DefaultOIDCIDTokenTTL
+}
+
+// RefreshTokenTTLOrDefault returns the refresh token lifetime or a default.
+func (c OIDCConfig) RefreshTokenTTLOrDefault() time.Duration {
+	if c.RefreshTokenTTL > 0 {
+		return c.RefreshTokenTTL
+	}
+	return DefaultOIDCRefreshTokenTTL
+}
+
+// User returns a user by subject.
+func (c OIDCConfig) User(subject string) (OIDCUser, bool) {
+	for _, user := range c.Users {
+		if user.Subject == subject {
+			return user, true
+		}
+	}
+	return OIDCUser{}, false
+}
+
+// Client returns a client by id.
+func (c OIDCConfig) Client(clientID string) (OIDCClient, bool) {
+	for _, client := range c.Clients {
+		if client.ClientID == clientID {
+			return client, true
+		}
+	}
+	return OIDCClient{}, false
+}
+
+// IsPublic returns if the client has no secret.
+func (c OIDCClient) IsPublic() bool {
+	return c.ClientSecret == ""
+}
+
+// AllowsRedirectURI returns if a redirect uri is registered for the client.
+// Redirect uris must match exactly.
+func (c OIDCClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == redirectURI {
+			return true
+		}
+	}
+	return false
+}
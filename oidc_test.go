@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+// oidcTestServer returns an app with a provider that has one user and one public client.
+func oidcTestServer(t *testing.T) *web.App {
+	t.Helper()
+	oidc, err := NewOIDC(OIDCConfig{
+		SigningAlgorithm: "ES256",
+		Users:            []OIDCUser{{Subject: "alice", Email: "alice@example.com", EmailVerified: true}},
+		Clients:          []OIDCClient{{ClientID: "spa", RedirectURIs: []string{"https://app.example.com/callback"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := web.New()
+	app.Register(oidc)
+	return app
+}
+
+// oidcAuthorize logs in as a user with a PKCE challenge for the verifier, returning the code.
+func oidcAuthorize(t *testing.T, app *web.App, verifier string) string {
+	t.Helper()
+	hash := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"openid email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6"},
+		"login":                 {"alice"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(hash[:])},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	}
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	if res.Code != http.StatusFound && res.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a redirect, got %d: %s", res.Code, res.Body.String())
+	}
+	location, err := url.Parse(res.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Host != "app.example.com" || location.Query().Get("state") != "xyz" || location.Query().Get("code") == "" {
+		t.Fatalf("unexpected redirect: %s", location)
+	}
+	return location.Query().Get("code")
+}
+
+// oidcToken exchanges a code, returning the status and response.
+func oidcToken(t *testing.T, app *web.App, code, verifier string) (int, map[string]interface{}) {
+	t.Helper()
+	form := url.Values{
+		"grant_type":    {GrantTypeAuthorizationCode},
+		"client_id":     {"spa"},
+		"code":          {code},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set(webutil.HeaderContentType, webutil.ContentTypeApplicationFormEncoded)
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, req)
+	var response map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid token response: %v: %s", err, res.Body.String())
+	}
+	return res.Code, response
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	app := oidcTestServer(t)
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	// a wrong verifier is refused, and uses up the code.
+	code := oidcAuthorize(t, app, verifier)
+	if status, response := oidcToken(t, app, code, "not-the-verifier-not-the-verifier-not-the-ver"); status != http.StatusBadRequest || response["error"] != OAuthErrorInvalidGrant {
+		t.Fatalf("expected a wrong verifier to be refused, got %d %v", status, response)
+	}
+	if status, _ := oidcToken(t, app, code, verifier); status != http.StatusBadRequest {
+		t.Fatalf("expected a code to be unusable after a failed exchange, got %d", status)
+	}
+
+	code = oidcAuthorize(t, app, verifier)
+	status, response := oidcToken(t, app, code, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("expected the code to be exchanged, got %d %v", status, response)
+	}
+	accessToken, _ := response["access_token"].(string)
+	if accessToken == "" || response["id_token"] == nil || response["refresh_token"] == nil {
+		t.Fatalf("expected access, id and refresh tokens, got %v", response)
+	}
+
+	// codes are single use.
+	if status, response := oidcToken(t, app, code, verifier); status != http.StatusBadRequest || response["error"] != OAuthErrorInvalidGrant {
+		t.Fatalf("expected a reused code to be refused, got %d %v", status, response)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected userinfo, got %d: %s", res.Code, res.Body.String())
+	}
+	var userinfo map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &userinfo); err != nil {
+		t.Fatal(err)
+	}
+	if userinfo["sub"] != "alice" || userinfo["email"] != "alice@example.com" || userinfo["email_verified"] != true {
+		t.Errorf("unexpected userinfo: %v", userinfo)
+	}
+
+	res = httptest.NewRecorder()
+	app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected userinfo without a token to be refused, got %d", res.Code)
+	}
+}
+func TestOIDCSweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &OIDC{
+		codes: map[string]*oidcGrant{
+			"expired": {Expires: now.Add(-time.Second)},
+			"valid":   {Expires: now.Add(time.Second)},
+		},
+		refreshTokens: map[string]*oidcGrant{
+			"expired": {Expires: now.Add(-time.Second)},
+			"valid":   {Expires: now.Add(time.Hour)},
+		},
+	}
+
+	o.sweep(now)
+	if _, ok := o.codes["expired"]; ok {
+		t.Error("expected the expired code to be swept")
+	}
+	if _, ok := o.refreshTokens["expired"]; ok {
+		t.Error("expected the expired refresh token to be swept")
+	}
+	if len(o.codes) != 1 || len(o.refreshTokens) != 1 {
+		t.Errorf("expected the valid grants to be kept, got %d codes and %d refresh tokens", len(o.codes), len(o.refreshTokens))
+	}
+
+	// sweeps happen at most once a code lifetime.
+	o.codes["stale"] = &oidcGrant{Expires: now}
+	o.sweep(now.Add(DefaultOIDCCodeTTL / 2))
+	if _, ok := o.codes["stale"]; !ok {
+		t.Error("expected no sweep within a code lifetime of the last")
+	}
+	o.sweep(now.Add(DefaultOIDCCodeTTL))
+	if _, ok := o.codes["stale"]; ok {
+		t.Error("expected the expired code to be swept a code lifetime later")
+	}
+}
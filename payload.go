@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/web"
+)
+
+const (
+	// MaxPayloadSize is the largest generated payload we'll serve.
+	MaxPayloadSize = 1024 * fileutil.Megabyte
+	// ContentTypeOctetStream is the content type of generated payloads.
+	ContentTypeOctetStream = "application/octet-stream"
+)
+
+// Payloads is a controller for endpoints that return generated payloads.
+// Payloads are deterministic and generated as they're read, so they're served with
+// `http.ServeContent` and support `Range` (single and multipart) and `If-Range` like files do.
+type Payloads struct{}
+
+// Register implements web.Controller.
+func (p Payloads) Register(app *web.App) {
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.HEAD} {
+		register("/bytes/:n", p.bytes)
+		register("/range/:n", p.alphabet)
+	}
+}
+
+// bytes serves `n` pseudo-random bytes, the same for a given `?seed=` (default 0).
+func (p Payloads) bytes(r *web.Ctx) web.Result {
+	size, err := p.size(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	var seed int64
+	if value := r.Request.URL.Query().Get("seed"); value != "" {
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return web.Text.BadRequest(ex.New("invalid seed; must be an integer", ex.OptMessagef("seed: %s", value)))
+		}
+	}
+	return p.serve(r, fmt.Sprintf(`"bytes-%d-%d"`, size, seed), NewRandomPayload(size, seed))
+}
+
+// alphabet serves `n` bytes of the repeated lowercase alphabet, so offsets are easy to check by eye.
+func (p Payloads) alphabet(r *web.Ctx) web.Result {
+	size, err := p.size(r)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	return p.serve(r, fmt.Sprintf(`"range-%d"`, size), NewAlphabetPayload(size))
+}
+
+func (p Payloads) size(r *web.Ctx) (int64, error) {
+	value, err := r.RouteParam("n")
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 || size > MaxPayloadSize {
+		return 0, ex.New("invalid size; must be an integer in range", ex.OptMessagef("size: %s, max: %d", value, MaxPayloadSize))
+	}
+	return size, nil
+}
+
+// serve writes a payload with its etag, letting `http.ServeContent` handle ranges and preconditions.
+func (p Payloads) serve(r *web.Ctx, etag string, payload io.ReadSeeker) web.Result {
+	r.Response.Header().Set(web.HeaderContentType, ContentTypeOctetStream)
+	r.Response.Header().Set(HeaderETag, etag)
+	clearIdentityEncoding(r)
+	http.ServeContent(r.Response, r.Request, "", time.Time{}, payload)
+	return nil
+}
+
+// NewAlphabetPayload returns a payload of the repeated lowercase alphabet.
+func NewAlphabetPayload(size int64) *PayloadReader {
+	return &PayloadReader{
+		Size: size,
+		Generate: func(offset int64, contents []byte) {
+			for index := range contents {
+				contents[index] = 'a' + byte((offset+int64(index))%26)
+			}
+		},
+	}
+}
+
+// NewRandomPayload returns a payload of pseudo-random bytes; sha256 of the seed and
+// block number for each 32 byte block, so any offset can be generated directly.
+func NewRandomPayload(size, seed int64) *PayloadReader {
+	var block int64 = -1
+	var digest [sha256.Size]byte
+	var input [16]byte
+	binary.BigEndian.PutUint64(input[:8], uint64(seed))
+	return &PayloadReader{
+		Size: size,
+		Generate: func(offset int64, contents []byte) {
+			for index := range contents {
+				position := offset + int64(index)
+				if position/sha256.Size != block {
+					block = position / sha256.Size
+					binary.BigEndian.PutUint64(input[8:], uint64(block))
+					digest = sha256.Sum256(input[:])
+				}
+				contents[index] = digest[position%sha256.Size]
+			}
+		},
+	}
+}
+
+// PayloadReader is an `io.ReadSeeker` over a generated payload of a fixed size.
+type PayloadReader struct {
+	Size int64
+	// Generate fills contents with the payload starting at an offset.
+	Generate func(offset int64, contents []byte)
+
+	offset int64
+}
+
+// Read implements io.Reader.
+func (pr *PayloadReader) Read(contents []byte) (int, error) {
+	if pr.offset >= pr.Size {
+		return 0, io.EOF
+	}
+	if remaining := pr.Size - pr.offset; int64(len(contents)) > remaining {
+		contents = contents[:remaining]
+	}
+	pr.Generate(pr.offset, contents)
+	pr.offset += int64(len(contents))
+	return len(contents), nil
+}
+
+// Seek implements io.Seeker.
+func (pr *PayloadReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pr.offset
+	case io.SeekEnd:
+		offset += pr.Size
+	default:
+		return 0, ex.New("invalid seek whence", ex.OptMessagef("whence: %d", whence))
+	}
+	if offset < 0 {
+		return 0, ex.New("invalid seek to a negative offset")
+	}
+	pr.offset = offset
+	return offset, nil
+}
+
+// clearIdentityEncoding removes the `Content-Encoding: identity` the app sets on every response;
+// `http.ServeContent` leaves out `Content-Length` for any encoded response, including identity.
+func clearIdentityEncoding(r *web.Ctx) {
+	if r.Response.Header().Get(web.HeaderContentEncoding) == web.ContentEncodingIdentity {
+		r.Response.Header().Del(web.HeaderContentEncoding)
+	}
+}
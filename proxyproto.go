@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+// PROXY protocol modes.
+const (
+	ProxyProtocolModeOptional = "optional"
+	ProxyProtocolModeStrict   = "strict"
+)
+
+// DefaultProxyProtocolHeaderTimeout is the default time a connection has to send its PROXY protocol header.
+const DefaultProxyProtocolHeaderTimeout = 5 * time.Second
+
+// PROXY protocol errors.
+const (
+	ErrProxyProtocolHeaderMissing ex.Class = "proxy protocol header missing"
+	ErrProxyProtocolHeaderInvalid ex.Class = "proxy protocol header invalid"
+)
+
+// PROXY protocol signatures.
+var (
+	proxyProtocolV1Signature = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+	// proxyProtocolV1MaxLength is the longest a v1 header may be, including the CRLF.
+	proxyProtocolV1MaxLength = 107
+	// proxyProtocolV2HeaderLength is the length of a v2 header before its addresses and TLVs.
+	proxyProtocolV2HeaderLength = 16
+)
+
+// PROXY protocol v2 TLV types.
+const (
+	ProxyTLVTypeALPN      = 0x01
+	ProxyTLVTypeAuthority = 0x02
+	ProxyTLVTypeCRC32C    = 0x03
+	ProxyTLVTypeNoop      = 0x04
+	ProxyTLVTypeUniqueID  = 0x05
+	ProxyTLVTypeSSL       = 0x20
+	ProxyTLVTypeNetNS     = 0x30
+	// ProxyTLVTypeGCP is the Google Cloud Private Service Connect TLV; the connection id as a big endian uint64.
+	ProxyTLVTypeGCP = 0xE0
+	// ProxyTLVTypeAWS is the AWS TLV; a subtype byte (1 for the VPC endpoint id) and the value.
+	ProxyTLVTypeAWS = 0xEA
+)
+
+// PROXY protocol v2 SSL sub-TLV types.
+const (
+	ProxyTLVTypeSSLVersion = 0x21
+	ProxyTLVTypeSSLCN      = 0x22
+	ProxyTLVTypeSSLCipher  = 0x23
+	ProxyTLVTypeSSLSigAlg  = 0x24
+	ProxyTLVTypeSSLKeyAlg  = 0x25
+)
+
+// proxyTLVNames are the names of the TLV types we know.
+var proxyTLVNames = map[byte]string{
+	ProxyTLVTypeALPN:       "alpn",
+	ProxyTLVTypeAuthority:  "authority",
+	ProxyTLVTypeCRC32C:     "crc32c",
+	ProxyTLVTypeNoop:       "noop",
+	ProxyTLVTypeUniqueID:   "uniqueID",
+	ProxyTLVTypeSSL:        "ssl",
+	ProxyTLVTypeNetNS:      "netns",
+	ProxyTLVTypeGCP:        "gcpPSCConnectionID",
+	ProxyTLVTypeAWS:        "aws",
+	ProxyTLVTypeSSLVersion: "sslVersion",
+	ProxyTLVTypeSSLCN:      "sslCN",
+	ProxyTLVTypeSSLCipher:  "sslCipher",
+	ProxyTLVTypeSSLSigAlg:  "sslSigAlg",
+	ProxyTLVTypeSSLKeyAlg:  "sslKeyAlg",
+}
+
+// NewProxyProtocol returns the PROXY protocol listener settings for the config.
+func NewProxyProtocol(cfg Config, log logger.Log) (*ProxyProtocol, error) {
+	pp := &ProxyProtocol{
+		Mode:          strings.ToLower(cfg.ProxyProtocol),
+		HeaderTimeout: cfg.ProxyProtocolHeaderTimeoutOrDefault(),
+		Log:           log,
+	}
+	if pp.Mode != "" && pp.Mode != ProxyProtocolModeOptional && pp.Mode != ProxyProtocolModeStrict {
+		return nil, ex.New("invalid proxy protocol mode; must be `optional` or `strict`", ex.OptMessagef("mode: %s", cfg.ProxyProtocol))
+	}
+	for _, source := range cfg.ProxyProtocolTrustedSources {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		cidrs, ok := TrustedProxyAliases[strings.ToLower(source)]
+		if !ok {
+			cidrs = []string{source}
+		}
+		for _, cidr := range cidrs {
+			network, err := parseCIDR(cidr)
+			if err != nil {
+				return nil, ex.New("invalid proxy protocol trusted source; must be a CIDR, an ip, `loopback` or `private`", ex.OptMessagef("source: %s", source), ex.OptInner(err))
+			}
+			pp.TrustedSources = append(pp.TrustedSources, network)
+		}
+	}
+	// anyone who can connect could otherwise claim any address; e.g. a loopback one, to be a trusted proxy.
+	if pp.Enabled() && len(pp.TrustedSources) == 0 {
+		return nil, ex.New("proxy protocol trusted sources are required with a proxy protocol mode; set PROXY_PROTOCOL_TRUSTED_SOURCES (e.g. `private`, or `0.0.0.0/0,::/0` to trust every source)")
+	}
+	return pp, nil
+}
+
+// ProxyProtocol reads PROXY protocol (v1 or v2) headers from the connections load balancers
+// make on behalf of clients, so the connection's remote address is the client's.
+//
+// In optional mode a header is read if the connection starts with one; in strict mode every
+// connection must start with one, and connections from sources that aren't trusted are refused.
+// Headers are only read from trusted sources, which must be configured.
+type ProxyProtocol struct {
+	Mode           string
+	TrustedSources []*net.IPNet
+	HeaderTimeout  time.Duration
+	Log            logger.Log
+}
+
+// Enabled returns if PROXY protocol headers are read.
+func (pp *ProxyProtocol) Enabled() bool {
+	return pp != nil && pp.Mode != ""
+}
+
+// Trusted returns if a source may send PROXY protocol headers.
+func (pp *ProxyProtocol) Trusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range pp.TrustedSources {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Listener wraps a listener to read PROXY protocol headers, if it's enabled.
+func (pp *ProxyProtocol) Listener(listener net.Listener) net.Listener {
+	if !pp.Enabled() {
+		return listener
+	}
+	return &ProxyProtocolListener{Listener: listener, ProxyProtocol: pp}
+}
+
+// ProxyProtocolListener is a listener whose connections read PROXY protocol headers.
+type ProxyProtocolListener struct {
+	net.Listener
+	ProxyProtocol *ProxyProtocol
+}
+
+// Accept accepts a connection; connections from untrusted sources are refused in strict mode.
+//
+// The header isn't read until the connection is first used, so a slow client doesn't hold up the others.
+func (ppl *ProxyProtocolListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ppl.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		trusted := ppl.ProxyProtocol.Trusted(conn.RemoteAddr())
+		if !trusted && ppl.ProxyProtocol.Mode == ProxyProtocolModeStrict {
+			logger.MaybeWarning(ppl.ProxyProtocol.Log, ex.New("proxy protocol connection refused; the source is not trusted", ex.OptMessagef("source: %v", conn.RemoteAddr())))
+			conn.Close()
+			continue
+		}
+		return &ProxyProtocolConn{Conn: conn, ProxyProtocol: ppl.ProxyProtocol, trusted: trusted}, nil
+	}
+}
+
+// ProxyProtocolConn is a connection that may start with a PROXY protocol header.
+// Its remote and local addresses are the ones in the header, if there is one.
+type ProxyProtocolConn struct {
+	net.Conn
+	ProxyProtocol *ProxyProtocol
+
+	trusted bool
+	once    sync.Once
+	reader  io.Reader
+	header  *ProxyHeader
+	err     error
+}
+
+// Header returns the connection's PROXY protocol header, reading it if it hasn't been,
+// or nil if it didn't send one.
+func (ppc *ProxyProtocolConn) Header() (*ProxyHeader, error) {
+	ppc.once.Do(ppc.readHeader)
+	return ppc.header, ppc.err
+}
+
+func (ppc *ProxyProtocolConn) readHeader() {
+	ppc.reader = ppc.Conn
+	if !ppc.trusted {
+		return
+	}
+	if timeout := ppc.ProxyProtocol.HeaderTimeout; timeout > 0 {
+		ppc.Conn.SetReadDeadline(time.Now().Add(timeout))
+		defer ppc.Conn.SetReadDeadline(time.Time{})
+	}
+	buffered := bufio.NewReaderSize(ppc.Conn, proxyProtocolV2HeaderLength)
+	ppc.reader = buffered
+	ppc.header, ppc.err = ReadProxyHeader(buffered)
+	if ppc.err == nil && ppc.header == nil && ppc.ProxyProtocol.Mode == ProxyProtocolModeStrict {
+		ppc.err = ex.New(ErrProxyProtocolHeaderMissing)
+	}
+	if ppc.err != nil {
+		logger.MaybeWarning(ppc.ProxyProtocol.Log, ex.New(ppc.err, ex.OptMessagef("source: %v", ppc.Conn.RemoteAddr())))
+		ppc.Conn.Close()
+	}
+}
+
+// Read implements net.Conn, reading past the header.
+func (ppc *ProxyProtocolConn) Read(contents []byte) (int, error) {
+	if _, err := ppc.Header(); err != nil {
+		return 0, err
+	}
+	return ppc.reader.Read(contents)
+}
+
+// RemoteAddr implements net.Conn; it's the header's source address, if there is one.
+func (ppc *ProxyProtocolConn) RemoteAddr() net.Addr {
+	if header, _ := ppc.Header(); header != nil && header.SourceAddr != nil {
+		return header.SourceAddr
+	}
+	return ppc.Conn.RemoteAddr()
+}
+
+// LocalAddr implements net.Conn; it's the header's destination address, if there is one.
+func (ppc *ProxyProtocolConn) LocalAddr() net.Addr {
+	if header, _ := ppc.Header(); header != nil && header.DestinationAddr != nil {
+		return header.DestinationAddr
+	}
+	return ppc.Conn.LocalAddr()
+}
+
+// NetConn returns the connection the header was read from.
+func (ppc *ProxyProtocolConn) NetConn() net.Conn {
+	return ppc.Conn
+}
+
+// ProxyHeader is a PROXY protocol header.
+type ProxyHeader struct {
+	Version int `json:"version"`
+	// Command is `PROXY`, or `LOCAL` for connections the proxy made itself (e.g. health checks).
+	Command string `json:"command"`
+	// Protocol is `TCP4`, `TCP6`, `UDP4`, `UDP6`, `UNIX`, `UNIXGRAM` or `UNKNOWN`.
+	Protocol string `json:"protocol"`
+	// SourceAddr and DestinationAddr are the client's connection to the proxy; nil for `LOCAL` and `UNKNOWN`.
+	SourceAddr      net.Addr   `json:"-"`
+	DestinationAddr net.Addr   `json:"-"`
+	TLVs            []ProxyTLV `json:"tlvs,omitempty"`
+}
+
+// TLV returns the first TLV of a type, if there is one.
+func (ph *ProxyHeader) TLV(tlvType byte) (ProxyTLV, bool) {
+	for _, tlv := range ph.TLVs {
+		if tlv.Type == tlvType {
+			return tlv, true
+		}
+	}
+	return ProxyTLV{}, false
+}
+
+// ProxyTLV is a PROXY protocol v2 type-length-value field.
+type ProxyTLV struct {
+	Type  byte   `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Value []byte `json:"value"`
+	// Text is the value decoded, for the types we know.
+	Text string `json:"text,omitempty"`
+	// SubTLVs are the fields nested in an SSL TLV.
+	SubTLVs []ProxyTLV `json:"subTLVs,omitempty"`
+}
+
+// ReadProxyHeader reads a PROXY protocol header from the start of a connection,
+// returning nil if the connection doesn't start with one.
+func ReadProxyHeader(reader *bufio.Reader) (*ProxyHeader, error) {
+	for length := 1; ; length++ {
+		peeked, err := reader.Peek(length)
+		if err != nil {
+			if err == io.EOF && len(peeked) > 0 {
+				return nil, nil
+			}
+			return nil, err
+		}
+		v1 := bytes.HasPrefix(proxyProtocolV1Signature, peeked)
+		v2 := bytes.HasPrefix(proxyProtocolV2Signature, peeked)
+		switch {
+		case !v1 && !v2:
+			return nil, nil
+		case v1 && length == len(proxyProtocolV1Signature):
+			return readProxyHeaderV1(reader)
+		case v2 && length == len(proxyProtocolV2Signature):
+			return readProxyHeaderV2(reader)
+		}
+	}
+}
+
+// readProxyHeaderV1 reads a v1 header, e.g. `PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n`.
+func readProxyHeaderV1(reader *bufio.Reader) (*ProxyHeader, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessage("v1 header is too long"))
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptInner(err))
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	header := &ProxyHeader{Version: 1, Command: "PROXY", Protocol: fields[1]}
+	if header.Protocol == "UNKNOWN" {
+		return header, nil
+	}
+	if len(fields) != 6 || (header.Protocol != "TCP4" && header.Protocol != "TCP6") {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v1 header: %q", line))
+	}
+	source, err := parseProxyAddrV1(header.Protocol, fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	destination, err := parseProxyAddrV1(header.Protocol, fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	header.SourceAddr, header.DestinationAddr = source, destination
+	return header, nil
+}
+
+func parseProxyAddrV1(protocol, host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (protocol == "TCP4") != (ip.To4() != nil) {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v1 %s address: %s", protocol, host))
+	}
+	parsed, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v1 port: %s", port))
+	}
+	return &net.TCPAddr{IP: ip, Port: int(parsed)}, nil
+}
+
+// readProxyHeaderV2 reads a v2 header; the signature, version and command, family and protocol,
+// the length of what follows, then the addresses and TLVs.
+func readProxyHeaderV2(reader *bufio.Reader) (*ProxyHeader, error) {
+	fixed := make([]byte, proxyProtocolV2HeaderLength)
+	if _, err := io.ReadFull(reader, fixed); err != nil {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptInner(err))
+	}
+	if version := fixed[12] >> 4; version != 2 {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v2 version: %d", version))
+	}
+	header := &ProxyHeader{Version: 2}
+	switch fixed[12] & 0x0F {
+	case 0x0:
+		header.Command = "LOCAL"
+	case 0x1:
+		header.Command = "PROXY"
+	default:
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v2 command: %#x", fixed[12]&0x0F))
+	}
+
+	rest := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(reader, rest); err != nil {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptInner(err))
+	}
+
+	var addressLength int
+	switch fixed[13] {
+	case 0x00:
+		header.Protocol = "UNKNOWN"
+	case 0x11, 0x12:
+		header.Protocol, addressLength = map[byte]string{0x11: "TCP4", 0x12: "UDP4"}[fixed[13]], 12
+	case 0x21, 0x22:
+		header.Protocol, addressLength = map[byte]string{0x21: "TCP6", 0x22: "UDP6"}[fixed[13]], 36
+	case 0x31, 0x32:
+		header.Protocol, addressLength = map[byte]string{0x31: "UNIX", 0x32: "UNIXGRAM"}[fixed[13]], 216
+	default:
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v2 family and protocol: %#x", fixed[13]))
+	}
+	if len(rest) < addressLength {
+		return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v2 %s addresses are %d bytes, not %d", header.Protocol, addressLength, len(rest)))
+	}
+	addresses := rest[:addressLength]
+
+	tlvs, err := parseProxyTLVs(rest[addressLength:])
+	if err != nil {
+		return nil, err
+	}
+	header.TLVs = tlvs
+
+	// the proxy's own connections (and ones it can't describe) keep the real addresses.
+	if header.Command == "LOCAL" {
+		return header, nil
+	}
+	switch header.Protocol {
+	case "TCP4", "TCP6":
+		size := (addressLength - 4) / 2
+		header.SourceAddr = &net.TCPAddr{IP: net.IP(addresses[:size]), Port: int(binary.BigEndian.Uint16(addresses[2*size:]))}
+		header.DestinationAddr = &net.TCPAddr{IP: net.IP(addresses[size : 2*size]), Port: int(binary.BigEndian.Uint16(addresses[2*size+2:]))}
+	case "UDP4", "UDP6":
+		size := (addressLength - 4) / 2
+		header.SourceAddr = &net.UDPAddr{IP: net.IP(addresses[:size]), Port: int(binary.BigEndian.Uint16(addresses[2*size:]))}
+		header.DestinationAddr = &net.UDPAddr{IP: net.IP(addresses[size : 2*size]), Port: int(binary.BigEndian.Uint16(addresses[2*size+2:]))}
+	case "UNIX", "UNIXGRAM":
+		header.SourceAddr = &net.UnixAddr{Name: string(bytes.TrimRight(addresses[:108], "\x00")), Net: "unix"}
+		header.DestinationAddr = &net.UnixAddr{Name: string(bytes.TrimRight(addresses[108:], "\x00")), Net: "unix"}
+	}
+	return header, nil
+}
+
+// parseProxyTLVs parses v2 TLVs; a type byte, a big endian length and the value.
+func parseProxyTLVs(contents []byte) (tlvs []ProxyTLV, err error) {
+	for len(contents) > 0 {
+		if len(contents) < 3 {
+			return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessage("v2 TLV is truncated"))
+		}
+		length := int(binary.BigEndian.Uint16(contents[1:3]))
+		if len(contents) < 3+length {
+			return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessagef("v2 TLV %#x is truncated", contents[0]))
+		}
+		tlv := ProxyTLV{Type: contents[0], Name: proxyTLVNames[contents[0]], Value: contents[3 : 3+length]}
+		contents = contents[3+length:]
+
+		switch tlv.Type {
+		case ProxyTLVTypeALPN, ProxyTLVTypeAuthority, ProxyTLVTypeNetNS, ProxyTLVTypeSSLVersion, ProxyTLVTypeSSLCN, ProxyTLVTypeSSLCipher, ProxyTLVTypeSSLSigAlg, ProxyTLVTypeSSLKeyAlg:
+			tlv.Text = string(tlv.Value)
+		case ProxyTLVTypeUniqueID, ProxyTLVTypeCRC32C:
+			tlv.Text = hex.EncodeToString(tlv.Value)
+		case ProxyTLVTypeGCP:
+			if len(tlv.Value) == 8 {
+				tlv.Text = strconv.FormatUint(binary.BigEndian.Uint64(tlv.Value), 10)
+			}
+		case ProxyTLVTypeAWS:
+			if len(tlv.Value) > 1 && tlv.Value[0] == 0x01 {
+				tlv.Name, tlv.Text = "awsVPCEndpointID", string(tlv.Value[1:])
+			}
+		case ProxyTLVTypeSSL:
+			// a client flags byte and a verify result, then the sub-TLVs.
+			if len(tlv.Value) < 5 {
+				return nil, ex.New(ErrProxyProtocolHeaderInvalid, ex.OptMessage("v2 SSL TLV is truncated"))
+			}
+			if tlv.SubTLVs, err = parseProxyTLVs(tlv.Value[5:]); err != nil {
+				return nil, err
+			}
+		}
+		tlvs = append(tlvs, tlv)
+	}
+	return
+}
+
+type proxyProtocolConnKey struct{}
+
+// WithProxyProtocolConn adds a connection to a context, for `ProxyProtocolHeader`; it can be used
+// as `http.Server.ConnContext`.
+func WithProxyProtocolConn(ctx context.Context, conn net.Conn) context.Context {
+	for conn != nil {
+		if ppc, ok := conn.(*ProxyProtocolConn); ok {
+			return context.WithValue(ctx, proxyProtocolConnKey{}, ppc)
+		}
+		conn = unwrapConn(conn)
+	}
+	return ctx
+}
+
+// unwrapConn returns the connection a connection wraps (e.g. a `tls.Conn`'s), or nil.
+func unwrapConn(conn net.Conn) net.Conn {
+	if wrapper, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		return wrapper.NetConn()
+	}
+	return nil
+}
+
+// ProxyProtocolHeader returns the PROXY protocol header of a request's connection, if it sent one.
+func ProxyProtocolHeader(req *http.Request) *ProxyHeader {
+	if ppc, ok := req.Context().Value(proxyProtocolConnKey{}).(*ProxyProtocolConn); ok {
+		header, _ := ppc.Header()
+		return header
+	}
+	return nil
+}
+
+// ProxyProtocolResponse describes a request's PROXY protocol header.
+type ProxyProtocolResponse struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode,omitempty"`
+	// RemoteAddr is the request's remote address; the header's source address, if it has one.
+	RemoteAddr string `json:"remoteAddr"`
+	// ProxyAddr is the address of the proxy that sent the header.
+	ProxyAddr   string       `json:"proxyAddr,omitempty"`
+	Header      *ProxyHeader `json:"header,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Destination string       `json:"destination,omitempty"`
+}
+
+// Register implements web.Controller.
+func (pp *ProxyProtocol) Register(app *web.App) {
+	app.GET("/proxy-protocol", pp.proxyProtocol)
+}
+
+// proxyProtocol reports the PROXY protocol header of the request's connection.
+func (pp *ProxyProtocol) proxyProtocol(r *web.Ctx) web.Result {
+	response := ProxyProtocolResponse{
+		Enabled:    pp.Enabled(),
+		Mode:       pp.Mode,
+		RemoteAddr: r.Request.RemoteAddr,
+	}
+	if ppc, ok := r.Request.Context().Value(proxyProtocolConnKey{}).(*ProxyProtocolConn); ok {
+		response.ProxyAddr = ppc.Conn.RemoteAddr().String()
+	}
+	if header := ProxyProtocolHeader(r.Request); header != nil {
+		response.Header = header
+		if header.SourceAddr != nil {
+			response.Source = header.SourceAddr.String()
+		}
+		if header.DestinationAddr != nil {
+			response.Destination = header.DestinationAddr.String()
+		}
+	}
+	return Negotiated(r, FormatJSON, response)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/blend/go-sdk/ex"
+)
+
+// proxyHeaderV2 builds a v2 header from its command, family and protocol, addresses and TLVs.
+func proxyHeaderV2(command, family byte, addresses []byte, tlvs ...[]byte) []byte {
+	rest := append([]byte{}, addresses...)
+	for _, tlv := range tlvs {
+		rest = append(rest, tlv...)
+	}
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(rest)))
+	return append(header, rest...)
+}
+
+// proxyTLV builds a v2 TLV.
+func proxyTLV(tlvType byte, value []byte) []byte {
+	return append([]byte{tlvType, byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	testCases := [...]struct {
+		Name        string
+		Input       string
+		Source      string
+		Destination string
+		Protocol    string
+		Err         bool
+	}{
+		{Name: "tcp4", Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET /", Protocol: "TCP4", Source: "192.0.2.1:56324", Destination: "198.51.100.1:443"},
+		{Name: "tcp6", Input: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", Protocol: "TCP6", Source: "[2001:db8::1]:56324", Destination: "[2001:db8::2]:443"},
+		{Name: "unknown", Input: "PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", Protocol: "UNKNOWN"},
+		{Name: "mismatched family", Input: "PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n", Err: true},
+		{Name: "bad port", Input: "PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n", Err: true},
+		{Name: "leading zero port", Input: "PROXY TCP4 192.0.2.1 198.51.100.1 0443 443\r\n", Err: true},
+		{Name: "missing fields", Input: "PROXY TCP4 192.0.2.1\r\n", Err: true},
+		{Name: "bad protocol", Input: "PROXY UDP4 192.0.2.1 198.51.100.1 1 2\r\n", Err: true},
+		{Name: "bare line feed", Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n", Err: true},
+		{Name: "too long", Input: "PROXY " + strings.Repeat("x", 200) + "\r\n", Err: true},
+	}
+	for _, tc := range testCases {
+		header, err := ReadProxyHeader(bufio.NewReader(strings.NewReader(tc.Input)))
+		if tc.Err {
+			if err == nil || !ex.Is(err, ErrProxyProtocolHeaderInvalid) {
+				t.Errorf("%s: expected an invalid header error, got %v", tc.Name, err)
+			}
+			continue
+		}
+		if err != nil || header == nil {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+			continue
+		}
+		if header.Version != 1 || header.Protocol != tc.Protocol {
+			t.Errorf("%s: expected v1 %s, got v%d %s", tc.Name, tc.Protocol, header.Version, header.Protocol)
+		}
+		if tc.Source != "" && (header.SourceAddr.String() != tc.Source || header.DestinationAddr.String() != tc.Destination) {
+			t.Errorf("%s: expected %s to %s, got %v to %v", tc.Name, tc.Source, tc.Destination, header.SourceAddr, header.DestinationAddr)
+		}
+	}
+}
+
+func TestReadProxyHeaderNone(t *testing.T) {
+	for _, input := range []string{"GET / HTTP/1.1\r\n\r\n", "PROX", "\r\n\r\nGET"} {
+		reader := bufio.NewReader(strings.NewReader(input))
+		header, err := ReadProxyHeader(reader)
+		if header != nil || err != nil {
+			t.Errorf("%q: expected no header, got %+v, %v", input, header, err)
+		}
+		// nothing is consumed from a connection without a header.
+		if buffered, _ := reader.Peek(len(input)); string(buffered) != input {
+			t.Errorf("%q: expected the input to be left unread, got %q", input, buffered)
+		}
+	}
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	tcp4 := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xDC, 0x04, 0x01, 0xBB}
+	ssl := append([]byte{0x01, 0, 0, 0, 0}, proxyTLV(ProxyTLVTypeSSLVersion, []byte("TLSv1.3"))...)
+
+	input := proxyHeaderV2(0x1, 0x11, tcp4,
+		proxyTLV(ProxyTLVTypeALPN, []byte("h2")),
+		proxyTLV(ProxyTLVTypeUniqueID, []byte{0xAB, 0xCD}),
+		proxyTLV(ProxyTLVTypeSSL, ssl),
+		proxyTLV(ProxyTLVTypeAWS, []byte("\x01vpce-123")),
+	)
+	reader := bufio.NewReader(bytes.NewReader(append(input, "GET /"...)))
+	header, err := ReadProxyHeader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Command != "PROXY" || header.Protocol != "TCP4" {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	if header.SourceAddr.String() != "192.0.2.1:56324" || header.DestinationAddr.String() != "198.51.100.1:443" {
+		t.Errorf("unexpected addresses: %v to %v", header.SourceAddr, header.DestinationAddr)
+	}
+	if tlv, ok := header.TLV(ProxyTLVTypeALPN); !ok || tlv.Text != "h2" {
+		t.Errorf("unexpected alpn: %+v", tlv)
+	}
+	if tlv, ok := header.TLV(ProxyTLVTypeUniqueID); !ok || tlv.Text != "abcd" {
+		t.Errorf("unexpected unique id: %+v", tlv)
+	}
+	if tlv, ok := header.TLV(ProxyTLVTypeSSL); !ok || len(tlv.SubTLVs) != 1 || tlv.SubTLVs[0].Text != "TLSv1.3" {
+		t.Errorf("unexpected ssl: %+v", tlv)
+	}
+	if tlv, ok := header.TLV(ProxyTLVTypeAWS); !ok || tlv.Name != "awsVPCEndpointID" || tlv.Text != "vpce-123" {
+		t.Errorf("unexpected aws: %+v", tlv)
+	}
+	if rest, _ := reader.Peek(5); string(rest) != "GET /" {
+		t.Errorf("expected the request to follow the header, got %q", rest)
+	}
+}
+
+func TestReadProxyHeaderV2Local(t *testing.T) {
+	header, err := ReadProxyHeader(bufio.NewReader(bytes.NewReader(proxyHeaderV2(0x0, 0x11, make([]byte, 12)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Command != "LOCAL" || header.SourceAddr != nil || header.DestinationAddr != nil {
+		t.Errorf("expected a local header without addresses, got %+v", header)
+	}
+}
+
+func TestReadProxyHeaderV2Invalid(t *testing.T) {
+	badVersion := proxyHeaderV2(0x1, 0x11, make([]byte, 12))
+	badVersion[12] = 0x11
+
+	testCases := [...]struct {
+		Name  string
+		Input []byte
+	}{
+		{Name: "version", Input: badVersion},
+		{Name: "command", Input: proxyHeaderV2(0x2, 0x11, make([]byte, 12))},
+		{Name: "family", Input: proxyHeaderV2(0x1, 0x41, make([]byte, 12))},
+		{Name: "short addresses", Input: proxyHeaderV2(0x1, 0x21, make([]byte, 12))},
+		{Name: "truncated", Input: proxyHeaderV2(0x1, 0x11, make([]byte, 12))[:20]},
+		{Name: "truncated tlv", Input: proxyHeaderV2(0x1, 0x11, make([]byte, 12), []byte{ProxyTLVTypeALPN, 0, 5, 'h'})},
+		{Name: "short tlv", Input: proxyHeaderV2(0x1, 0x11, make([]byte, 12), []byte{ProxyTLVTypeALPN, 0})},
+		{Name: "truncated ssl", Input: proxyHeaderV2(0x1, 0x11, make([]byte, 12), proxyTLV(ProxyTLVTypeSSL, []byte{1, 0}))},
+	}
+	for _, tc := range testCases {
+		_, err := ReadProxyHeader(bufio.NewReader(bytes.NewReader(tc.Input)))
+		if err == nil || !ex.Is(err, ErrProxyProtocolHeaderInvalid) {
+			t.Errorf("%s: expected an invalid header error, got %v", tc.Name, err)
+		}
+	}
+}
+
+func TestNewProxyProtocolTrustedSources(t *testing.T) {
+	testCases := [...]struct {
+		Mode    string
+		Sources []string
+		Err     bool
+	}{
+		{},
+		{Mode: ProxyProtocolModeOptional, Err: true},
+		{Mode: ProxyProtocolModeStrict, Sources: []string{" "}, Err: true},
+		{Mode: ProxyProtocolModeOptional, Sources: []string{"private"}},
+		{Mode: ProxyProtocolModeStrict, Sources: []string{"0.0.0.0/0", "::/0"}},
+	}
+	for _, tc := range testCases {
+		_, err := NewProxyProtocol(Config{ProxyProtocol: tc.Mode, ProxyProtocolTrustedSources: tc.Sources}, nil)
+		if (err != nil) != tc.Err {
+			t.Errorf("%q %v: expected error %v, got %v", tc.Mode, tc.Sources, tc.Err, err)
+		}
+	}
+}
+
+func TestProxyProtocolTrusted(t *testing.T) {
+	pp, err := NewProxyProtocol(Config{ProxyProtocol: ProxyProtocolModeOptional, ProxyProtocolTrustedSources: []string{"10.0.0.0/8"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pp.Trusted(&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1}) {
+		t.Error("expected a source in a trusted range to be trusted")
+	}
+	if pp.Trusted(&net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 1}) {
+		t.Error("expected a source outside the trusted ranges not to be trusted")
+	}
+	if pp.Trusted(&net.UnixAddr{Name: "/tmp/echo.sock"}) {
+		t.Error("expected a non tcp source not to be trusted")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// Rate limit algorithms.
+const (
+	RateLimitAlgorithmTokenBucket   = "token-bucket"
+	RateLimitAlgorithmSlidingWindow = "sliding-window"
+)
+
+// Rate limit keys.
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyRoute  = "route"
+	RateLimitKeyJWT    = "jwt"
+	RateLimitKeyHeader = "header:"
+)
+
+// Rate limit headers.
+const (
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+const (
+	// DefaultRateLimitWindow is the default rate limit window.
+	DefaultRateLimitWindow = time.Minute
+	// DefaultRateLimitAlgorithm is the default rate limit algorithm.
+	DefaultRateLimitAlgorithm = RateLimitAlgorithmTokenBucket
+	// DefaultRateLimitKey is the default rate limit key.
+	DefaultRateLimitKey = RateLimitKeyIP
+)
+
+// DefaultRateLimitExemptPaths are the paths that aren't rate limited by default; the health check path.
+var DefaultRateLimitExemptPaths = []string{"/"}
+
+// RateLimiter limits how often each key may act. Implementations are safe for concurrent use,
+// and forget keys once they've been idle long enough to be back at their full limit.
+type RateLimiter interface {
+	// Allow takes an action for a key if the limit allows it.
+	Allow(key string, now time.Time) RateLimitResult
+}
+
+// RateLimitResult is the state of a key's limit after an action.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the key is back at its full limit.
+	Reset time.Duration
+	// RetryAfter is how long until the next action would be allowed, if this one wasn't.
+	RetryAfter time.Duration
+}
+
+// NewTokenBucketLimiter returns a limiter that allows `limit` actions per window on average,
+// in bursts of up to `burst`.
+func NewTokenBucketLimiter(limit int, window time.Duration, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		Limit:   limit,
+		Window:  window,
+		Burst:   burst,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// TokenBucketLimiter is a token bucket rate limiter; each key's bucket holds up to `Burst` tokens
+// and refills at `Limit` tokens per `Window`.
+type TokenBucketLimiter struct {
+	sync.Mutex
+	Limit  int
+	Window time.Duration
+	Burst  int
+
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Allow implements RateLimiter.
+func (tbl *TokenBucketLimiter) Allow(key string, now time.Time) (result RateLimitResult) {
+	tbl.Lock()
+	defer tbl.Unlock()
+
+	rate := float64(tbl.Limit) / float64(tbl.Window) // tokens per nanosecond
+	capacity := float64(tbl.Burst)
+	tbl.sweep(now, rate, capacity)
+
+	bucket, ok := tbl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{Tokens: capacity, Updated: now}
+		tbl.buckets[key] = bucket
+	}
+	bucket.Tokens = math.Min(capacity, bucket.Tokens+float64(now.Sub(bucket.Updated))*rate)
+	bucket.Updated = now
+
+	result.Limit = tbl.Burst
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.Tokens) / rate)
+	}
+	result.Remaining = int(bucket.Tokens)
+	result.Reset = time.Duration((capacity - bucket.Tokens) / rate)
+	return
+}
+
+// sweep forgets buckets that have refilled, at most once a window.
+func (tbl *TokenBucketLimiter) sweep(now time.Time, rate, capacity float64) {
+	if now.Sub(tbl.lastSweep) < tbl.Window {
+		return
+	}
+	tbl.lastSweep = now
+	for key, bucket := range tbl.buckets {
+		if bucket.Tokens+float64(now.Sub(bucket.Updated))*rate >= capacity {
+			delete(tbl.buckets, key)
+		}
+	}
+}
+
+// NewSlidingWindowLimiter returns a limiter that allows `limit` actions in any window.
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		Limit:   limit,
+		Window:  window,
+		actions: map[string][]time.Time{},
+	}
+}
+
+// SlidingWindowLimiter is a sliding log rate limiter; it keeps the times of each key's actions
+// in the last window (at most `Limit` of them) and allows an action if there's room.
+type SlidingWindowLimiter struct {
+	sync.Mutex
+	Limit  int
+	Window time.Duration
+
+	actions   map[string][]time.Time
+	lastSweep time.Time
+}
+
+// Allow implements RateLimiter.
+func (swl *SlidingWindowLimiter) Allow(key string, now time.Time) (result RateLimitResult) {
+	swl.Lock()
+	defer swl.Unlock()
+	swl.sweep(now)
+
+	actions := swl.actions[key]
+	for len(actions) > 0 && now.Sub(actions[0]) >= swl.Window {
+		actions = actions[1:]
+	}
+
+	result.Limit = swl.Limit
+	if len(actions) < swl.Limit {
+		actions = append(actions, now)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = actions[0].Add(swl.Window).Sub(now)
+	}
+	swl.actions[key] = actions
+	result.Remaining = swl.Limit - len(actions)
+	if len(actions) > 0 {
+		result.Reset = actions[len(actions)-1].Add(swl.Window).Sub(now)
+	}
+	return
+}
+
+// sweep forgets keys with no actions in the last window, at most once a window.
+func (swl *SlidingWindowLimiter) sweep(now time.Time) {
+	if now.Sub(swl.lastSweep) < swl.Window {
+		return
+	}
+	swl.lastSweep = now
+	for key, actions := range swl.actions {
+		if len(actions) == 0 || now.Sub(actions[len(actions)-1]) >= swl.Window {
+			delete(swl.actions, key)
+		}
+	}
+}
+
+// NewRateLimit returns the rate limit middleware for the config.
+// It's disabled unless a limit is configured; JWT subjects are verified against the given keys,
+// and client ips are resolved by the given resolver.
+func NewRateLimit(cfg Config, jwtKeys []JWTKey, clientIPs *ClientIPResolver) (*RateLimit, error) {
+	rl := &RateLimit{
+		Config:       cfg,
+		JWTInspector: JWTInspector{Keys: jwtKeys},
+		ClientIPs:    clientIPs,
+		Keys:         strings.Split(cfg.RateLimitKeyOrDefault(), "+"),
+	}
+	for _, key := range rl.Keys {
+		if key != RateLimitKeyIP && key != RateLimitKeyRoute && key != RateLimitKeyJWT && (!strings.HasPrefix(key, RateLimitKeyHeader) || key == RateLimitKeyHeader) {
+			return nil, ex.New("invalid rate limit key; must be `ip`, `route`, `jwt` or `header:<name>`, or a combination like `ip+route`", ex.OptMessagef("key: %s", key))
+		}
+	}
+	if cfg.RateLimit <= 0 {
+		return rl, nil
+	}
+
+	switch algorithm := cfg.RateLimitAlgorithmOrDefault(); algorithm {
+	case RateLimitAlgorithmTokenBucket:
+		rl.Limiter = NewTokenBucketLimiter(cfg.RateLimit, cfg.RateLimitWindowOrDefault(), cfg.RateLimitBurstOrDefault())
+	case RateLimitAlgorithmSlidingWindow:
+		rl.Limiter = NewSlidingWindowLimiter(cfg.RateLimit, cfg.RateLimitWindowOrDefault())
+	default:
+		return nil, ex.New("invalid rate limit algorithm; must be `token-bucket` or `sliding-window`", ex.OptMessagef("algorithm: %s", algorithm))
+	}
+	return rl, nil
+}
+
+// RateLimit is web middleware that limits requests by key, responding 429 when a key is over its limit.
+type RateLimit struct {
+	Config       Config
+	Limiter      RateLimiter
+	JWTInspector JWTInspector
+	ClientIPs    *ClientIPResolver
+	// Keys are what requests are keyed by; each of `ip`, `route`, `jwt` or `header:<name>`.
+	Keys []string
+}
+
+// Middleware limits the requests for an action, adding the `RateLimit-*` headers to every response
+// and `Retry-After` to 429s. Exempt paths aren't limited, and neither are CORS preflights; they're sent by browsers, not callers,
+// and a refused preflight would hide the real response (and its `Retry-After`) from the caller.
+//
+// It should be inside the CORS middleware, so 429s have the CORS headers browsers need to read them.
+func (rl *RateLimit) Middleware(action web.Action) web.Action {
+	return func(r *web.Ctx) web.Result {
+		if rl.Limiter == nil || IsPreflight(r.Request) || rl.Exempt(r.Request.URL.Path) {
+			return action(r)
+		}
+		result := rl.Limiter.Allow(rl.Key(r), time.Now())
+
+		header := r.Response.Header()
+		header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		header.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+		header.Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d", rl.Config.RateLimit, ceilSeconds(rl.Config.RateLimitWindowOrDefault())))
+		if !result.Allowed {
+			header.Set(HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return web.JSON.Status(http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded; retry in %v", result.RetryAfter.Round(time.Millisecond)))
+		}
+		return action(r)
+	}
+}
+
+// Exempt returns if a path isn't rate limited.
+func (rl *RateLimit) Exempt(path string) bool {
+	for _, exempt := range rl.Config.RateLimitExemptPathsOrDefault() {
+		exempt = strings.TrimSpace(exempt)
+		if exempt == path || (strings.HasSuffix(exempt, "*") && strings.HasPrefix(path, strings.TrimSuffix(exempt, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the rate limit key for a request.
+// Requests without the header, or a valid token, for a `header:` or `jwt` key are keyed by ip instead.
+func (rl *RateLimit) Key(r *web.Ctx) string {
+	parts := make([]string, 0, len(rl.Keys))
+	for _, key := range rl.Keys {
+		var value string
+		switch {
+		case key == RateLimitKeyRoute:
+			if r.Route != nil {
+				value = r.Request.Method + " " + r.Route.Path
+			}
+		case key == RateLimitKeyJWT:
+			if token, ok := BearerToken(r.Request); ok {
+				if inspection := rl.JWTInspector.Inspect(token); inspection.Valid {
+					value, _ = inspection.Claims["sub"].(string)
+				}
+			}
+		case strings.HasPrefix(key, RateLimitKeyHeader):
+			value = r.Request.Header.Get(strings.TrimPrefix(key, RateLimitKeyHeader))
+		}
+		if value == "" && key != RateLimitKeyRoute {
+			key, value = RateLimitKeyIP, rl.ClientIPs.Resolve(r.Request).IP
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, "|")
+}
+
+// ceilSeconds returns a duration in whole seconds, rounded up.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blend/go-sdk/web"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 10 a second on average, in bursts of up to 3.
+	limiter := NewTokenBucketLimiter(10, time.Second, 3)
+
+	testCases := [...]struct {
+		Key        string
+		At         time.Duration
+		Allowed    bool
+		Remaining  int
+		RetryAfter time.Duration
+	}{
+		{Key: "a", At: 0, Allowed: true, Remaining: 2},
+		{Key: "a", At: 0, Allowed: true, Remaining: 1},
+		{Key: "a", At: 0, Allowed: true, Remaining: 0},
+		{Key: "a", At: 0, Allowed: false, Remaining: 0, RetryAfter: 100 * time.Millisecond},
+		{Key: "a", At: 50 * time.Millisecond, Allowed: false, Remaining: 0, RetryAfter: 50 * time.Millisecond},
+		{Key: "b", At: 50 * time.Millisecond, Allowed: true, Remaining: 2},
+		{Key: "a", At: 100 * time.Millisecond, Allowed: true, Remaining: 0},
+		{Key: "a", At: time.Hour, Allowed: true, Remaining: 2},
+	}
+	for index, tc := range testCases {
+		result := limiter.Allow(tc.Key, start.Add(tc.At))
+		if result.Allowed != tc.Allowed || result.Remaining != tc.Remaining || result.Limit != 3 {
+			t.Errorf("%d: expected allowed %v remaining %d, got %+v", index, tc.Allowed, tc.Remaining, result)
+		}
+		if !tc.Allowed && (result.RetryAfter < tc.RetryAfter-time.Millisecond || result.RetryAfter > tc.RetryAfter+time.Millisecond) {
+			t.Errorf("%d: expected retry after %v, got %v", index, tc.RetryAfter, result.RetryAfter)
+		}
+	}
+	// "b" refilled long ago and should have been swept.
+	if _, ok := limiter.buckets["b"]; ok {
+		t.Error("expected the refilled bucket to be swept")
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewSlidingWindowLimiter(2, time.Second)
+
+	testCases := [...]struct {
+		Key        string
+		At         time.Duration
+		Allowed    bool
+		Remaining  int
+		RetryAfter time.Duration
+		Reset      time.Duration
+	}{
+		{Key: "a", At: 0, Allowed: true, Remaining: 1, Reset: time.Second},
+		{Key: "a", At: 400 * time.Millisecond, Allowed: true, Remaining: 0, Reset: time.Second},
+		{Key: "a", At: 600 * time.Millisecond, Allowed: false, Remaining: 0, RetryAfter: 400 * time.Millisecond, Reset: 800 * time.Millisecond},
+		{Key: "b", At: 600 * time.Millisecond, Allowed: true, Remaining: 1, Reset: time.Second},
+		// the first action leaves the window after a second.
+		{Key: "a", At: time.Second, Allowed: true, Remaining: 0, Reset: time.Second},
+		{Key: "a", At: 1200 * time.Millisecond, Allowed: false, Remaining: 0, RetryAfter: 200 * time.Millisecond, Reset: 800 * time.Millisecond},
+		{Key: "a", At: time.Hour, Allowed: true, Remaining: 1, Reset: time.Second},
+	}
+	for index, tc := range testCases {
+		result := limiter.Allow(tc.Key, start.Add(tc.At))
+		if result.Allowed != tc.Allowed || result.Remaining != tc.Remaining || result.RetryAfter != tc.RetryAfter || result.Reset != tc.Reset || result.Limit != 2 {
+			t.Errorf("%d: expected allowed %v remaining %d retry after %v reset %v, got %+v", index, tc.Allowed, tc.Remaining, tc.RetryAfter, tc.Reset, result)
+		}
+	}
+	if _, ok := limiter.actions["b"]; ok {
+		t.Error("expected the idle key to be swept")
+	}
+}
+
+func TestRateLimitCORS(t *testing.T) {
+	cfg := Config{RateLimit: 1, CORSAllowedOrigins: []string{"https://app.example.com"}}
+	rateLimit, err := NewRateLimit(cfg, nil, &ClientIPResolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cors, err := NewCORS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as main orders them; the last is the outermost.
+	app := web.New(web.OptUse(rateLimit.Middleware), web.OptUse(cors.Middleware))
+	app.GET("/limited", func(r *web.Ctx) web.Result { return web.Text.Result("echo") })
+	cors.RegisterPreflight(app)
+
+	request := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/limited", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set(HeaderOrigin, "https://app.example.com")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		return res
+	}
+
+	// preflights don't use up the limit.
+	for index := 0; index < 3; index++ {
+		if res := request(http.MethodOptions, map[string]string{HeaderAccessControlRequestMethod: http.MethodGet}); res.Code != http.StatusNoContent {
+			t.Fatalf("expected the preflight to be answered, got %d", res.Code)
+		}
+	}
+	if res := request(http.MethodGet, nil); res.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be allowed, got %d", res.Code)
+	}
+	res := request(http.MethodGet, nil)
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second request to be limited, got %d", res.Code)
+	}
+	if origin := res.Header().Get(HeaderAccessControlAllowOrigin); origin != "https://app.example.com" {
+		t.Errorf("expected the 429 to have the CORS headers, got origin %q", origin)
+	}
+	if res.Header().Get(HeaderRetryAfter) == "" {
+		t.Error("expected the 429 to have a Retry-After")
+	}
+}
+
+func TestRateLimitExempt(t *testing.T) {
+	testCases := [...]struct {
+		Exempt   []string
+		Path     string
+		Expected bool
+	}{
+		{Path: "/", Expected: true},
+		{Path: "/headers"},
+		{Exempt: []string{"/status", "/admin/*"}, Path: "/status", Expected: true},
+		{Exempt: []string{"/status", "/admin/*"}, Path: "/admin/connections", Expected: true},
+		{Exempt: []string{"/status", "/admin/*"}, Path: "/admin"},
+		{Exempt: []string{"/status", "/admin/*"}, Path: "/"},
+		{Exempt: []string{"/status"}, Path: "/status/x"},
+	}
+	for _, tc := range testCases {
+		rl := &RateLimit{Config: Config{RateLimitExemptPaths: tc.Exempt}}
+		if actual := rl.Exempt(tc.Path); actual != tc.Expected {
+			t.Errorf("%v %s: expected %v, got %v", tc.Exempt, tc.Path, tc.Expected, actual)
+		}
+	}
+}
+
+func TestRateLimitExemptProbe(t *testing.T) {
+	rateLimit, err := NewRateLimit(Config{RateLimit: 1}, nil, &ClientIPResolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := web.New(web.OptUse(rateLimit.Middleware))
+	app.GET("/", func(r *web.Ctx) web.Result { return web.Text.Result("echo") })
+	for index := 0; index < 5; index++ {
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected probes not to be limited, got %d", res.Code)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/web"
+)
+
+// DefaultRawMaxSize is the default largest request `/raw` returns.
+const DefaultRawMaxSize = int64(fileutil.Megabyte)
+
+// ErrRawTooLarge is returned when a request is larger than `/raw` will return.
+const ErrRawTooLarge ex.Class = "raw request too large"
+
+// recordingReadAhead is the most the server reads ahead of a request; the size of its read buffer.
+const recordingReadAhead = 4 << 10
+
+// RawRoute is the route of the raw request endpoint.
+const RawRoute = "/raw"
+
+// ContentTypeHTTPMessage is the content type of an http message.
+const ContentTypeHTTPMessage = "message/http"
+
+// RecordingListener is a listener whose connections record what they read, for `/raw`.
+type RecordingListener struct {
+	net.Listener
+	MaxSize int64
+}
+
+// Accept implements net.Listener.
+func (rl *RecordingListener) Accept() (net.Conn, error) {
+	conn, err := rl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingConn{Conn: conn, MaxSize: rl.MaxSize}, nil
+}
+
+// RecordingConn is a connection that records what it reads. Each write (i.e. response) starts
+// a new recording, keeping only what the server may have read ahead of the request it's responding to,
+// so the recording holds the current request as it was sent.
+//
+// Only the request head is needed (`/raw` reads the body itself), so recording stops `recordingReadAhead`
+// bytes past the end of the head, and heads over `MaxSize` aren't recorded whole.
+type RecordingConn struct {
+	net.Conn
+	MaxSize int64
+
+	sync.Mutex
+	recording bytes.Buffer
+	// limit is where recording stops, once the end of the head has been found.
+	limit int
+	// scanned is how much of the recording has been searched for the end of the head.
+	scanned int
+	// dropped is if anything read wasn't recorded, so the recording doesn't run up to what's read next.
+	dropped   bool
+	truncated bool
+}
+
+// Read implements net.Conn.
+func (rc *RecordingConn) Read(contents []byte) (int, error) {
+	read, err := rc.Conn.Read(contents)
+	if read > 0 {
+		rc.Lock()
+		rc.record(contents[:read])
+		rc.Unlock()
+	}
+	return read, err
+}
+
+// record records what's read, up to the limit; the conn must be locked.
+func (rc *RecordingConn) record(contents []byte) {
+	limit := int(rc.MaxSize)
+	if rc.limit > 0 {
+		limit = rc.limit
+	}
+	if remaining := limit - rc.recording.Len(); remaining < len(contents) {
+		contents = contents[:maxInt(remaining, 0)]
+		rc.dropped = true
+		if rc.limit == 0 {
+			rc.truncated = true
+		}
+	}
+	rc.recording.Write(contents)
+	rc.findHeadEnd()
+}
+
+// findHeadEnd looks for the end of the recorded head (an empty line), and limits the recording once it's found.
+func (rc *RecordingConn) findHeadEnd() {
+	if rc.limit > 0 {
+		return
+	}
+	recorded := rc.recording.Bytes()
+	// the empty line can straddle reads.
+	from := maxInt(rc.scanned-3, 0)
+	rc.scanned = len(recorded)
+	end := -1
+	if index := bytes.Index(recorded[from:], []byte("\r\n\r\n")); index >= 0 {
+		end = from + index + 4
+	}
+	if index := bytes.Index(recorded[from:], []byte("\n\n")); index >= 0 && (end < 0 || from+index+2 < end) {
+		end = from + index + 2
+	}
+	if end < 0 {
+		return
+	}
+	rc.limit = end + recordingReadAhead
+	if rc.recording.Len() > rc.limit {
+		rc.recording.Truncate(rc.limit)
+		rc.dropped = true
+	}
+}
+
+// Write implements net.Conn.
+func (rc *RecordingConn) Write(contents []byte) (int, error) {
+	rc.Lock()
+	if recorded := rc.recording.Bytes(); rc.dropped {
+		rc.recording.Reset()
+	} else if len(recorded) > recordingReadAhead {
+		rc.recording.Truncate(copy(recorded, recorded[len(recorded)-recordingReadAhead:]))
+	}
+	// the kept read-ahead is mostly the end of the last request, so the next head ends after it.
+	// (a pipelined head that's already been read is recorded until the next empty line, or the max size.)
+	rc.limit, rc.scanned, rc.dropped, rc.truncated = 0, rc.recording.Len(), false, false
+	rc.Unlock()
+	return rc.Conn.Write(contents)
+}
+
+// NetConn returns the recorded connection.
+func (rc *RecordingConn) NetConn() net.Conn {
+	return rc.Conn
+}
+
+// Recording returns a copy of what's been read since the last write, and if it was more than the max size.
+func (rc *RecordingConn) Recording() ([]byte, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+	return append([]byte(nil), rc.recording.Bytes()...), rc.truncated
+}
+
+type recordingConnKey struct{}
+
+// WithRecordingConn adds a recording connection to a context, for `/raw`.
+func WithRecordingConn(ctx context.Context, conn net.Conn) context.Context {
+	if rc, ok := conn.(*RecordingConn); ok {
+		return context.WithValue(ctx, recordingConnKey{}, rc)
+	}
+	return ctx
+}
+
+// Raw is a controller for the endpoint that returns requests exactly as they were sent;
+// header order and casing, line endings, chunk framing and trailers, none of which survive
+// being parsed into an `http.Request`.
+//
+// Request decompression must skip its route; see `DecompressRequest`.
+type Raw struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (raw Raw) Register(app *web.App) {
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.HEAD, app.POST, app.PUT, app.PATCH, app.DELETE, app.OPTIONS} {
+		register(RawRoute, raw.raw)
+	}
+}
+
+// raw takes over the connection, reads the rest of the request as it was sent,
+// returns the whole of it as `message/http` and closes the connection.
+func (raw Raw) raw(r *web.Ctx) web.Result {
+	rc, ok := r.Request.Context().Value(recordingConnKey{}).(*RecordingConn)
+	if !ok {
+		return web.Text.Status(http.StatusNotImplemented, "raw requests are only recorded for plain http/1.x connections")
+	}
+	conn, rw, err := Hijack(r.Request)
+	if err != nil {
+		return web.Text.InternalError(err)
+	}
+	defer conn.Close()
+
+	recorded, truncated := rc.Recording()
+	// what the server has read but not used is the start of the body.
+	head, ok := findRequestHead(recorded[:maxInt(len(recorded)-rw.Reader.Buffered(), 0)], r.Request)
+	if !ok && truncated {
+		raw.write(rw, http.StatusRequestEntityTooLarge, web.ContentTypeText, []byte(ex.New(ErrRawTooLarge, ex.OptMessagef("limit: %d", raw.Config.RawMaxSizeOrDefault())).Error()))
+		return nil
+	}
+	if !ok {
+		raw.write(rw, http.StatusInternalServerError, web.ContentTypeText, []byte("the request line was not recorded"))
+		return nil
+	}
+
+	if expectsContinue(r.Request) {
+		rw.WriteString("HTTP/1.1 100 Continue\r\n\r\n")
+		rw.Flush()
+	}
+	body, err := readRawBody(r.Request, rw.Reader, raw.Config.RawMaxSizeOrDefault()-int64(len(head)))
+	if ex.Is(err, ErrRawTooLarge) {
+		raw.write(rw, http.StatusRequestEntityTooLarge, web.ContentTypeText, []byte(err.Error()))
+		return nil
+	}
+	if err != nil {
+		raw.write(rw, http.StatusBadRequest, web.ContentTypeText, []byte(err.Error()))
+		return nil
+	}
+	raw.write(rw, http.StatusOK, ContentTypeHTTPMessage, append(head, body...))
+	return nil
+}
+
+// findRequestHead returns the recorded request line and headers of a request;
+// the recording may start with the end of the requests before it.
+func findRequestHead(recorded []byte, req *http.Request) ([]byte, bool) {
+	requestLine := []byte(req.Method + " " + req.RequestURI + " ")
+	for end := len(recorded); end >= 0; {
+		start := bytes.LastIndex(recorded[:end], requestLine)
+		if start < 0 {
+			return nil, false
+		}
+		if start == 0 || recorded[start-1] == '\n' {
+			return recorded[start:], true
+		}
+		end = start
+	}
+	return nil, false
+}
+
+// write writes a whole response to a hijacked connection.
+func (raw Raw) write(rw *bufio.ReadWriter, statusCode int, contentType string, body []byte) {
+	fmt.Fprintf(rw, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	fmt.Fprintf(rw, "%s: %s\r\n", web.HeaderContentType, contentType)
+	fmt.Fprintf(rw, "%s: %d\r\n", web.HeaderContentLength, len(body))
+	fmt.Fprintf(rw, "%s: close\r\n\r\n", web.HeaderConnection)
+	rw.Write(body)
+	rw.Flush()
+}
+
+// expectsContinue returns if a request with a body is waiting for `100 Continue` to send it.
+func expectsContinue(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Expect"), "100-continue") && (req.ContentLength != 0 || len(req.TransferEncoding) > 0)
+}
+
+// readRawBody reads a request body as it was sent, by its content length or its chunks (and trailers).
+func readRawBody(req *http.Request, reader *bufio.Reader, limit int64) ([]byte, error) {
+	if len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked" {
+		return readRawChunks(reader, limit)
+	}
+	if req.ContentLength <= 0 {
+		return nil, nil
+	}
+	if req.ContentLength > limit {
+		return nil, ex.New(ErrRawTooLarge, ex.OptMessagef("content length: %d", req.ContentLength))
+	}
+	body := make([]byte, req.ContentLength)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, ex.New(err)
+	}
+	return body, nil
+}
+
+// readRawChunks reads a chunked body as it was sent; each chunk's size line (with any extensions),
+// data and CRLF, then the last chunk and the trailers.
+func readRawChunks(reader *bufio.Reader, limit int64) (body []byte, err error) {
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", ex.New(err)
+		}
+		body = append(body, line...)
+		if int64(len(body)) > limit {
+			return "", ex.New(ErrRawTooLarge)
+		}
+		return line, nil
+	}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		sizeField := strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil || size < 0 {
+			return nil, ex.New("invalid chunk size", ex.OptMessagef("chunk size: %q", sizeField))
+		}
+		if size == 0 {
+			break
+		}
+		if int64(len(body))+size > limit {
+			return nil, ex.New(ErrRawTooLarge)
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, ex.New(err)
+		}
+		body = append(body, chunk...)
+		if _, err := readLine(); err != nil {
+			return nil, err
+		}
+	}
+	// the trailers end with an empty line.
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "\r\n" || line == "\n" {
+			return body, nil
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
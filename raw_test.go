@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// startRawServer serves an app with the raw endpoint (and request decompression, as main sets it up)
+// on a recording listener, returning its address.
+func startRawServer(t *testing.T, cfg Config) string {
+	t.Helper()
+	app := web.New(web.OptUse(DecompressRequest(cfg, RawRoute)))
+	app.Register(Raw{Config: cfg})
+	server := NewServer(app)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: server.Handler(), ConnContext: server.ConnContext}
+	go httpServer.Serve(&RecordingListener{Listener: listener, MaxSize: cfg.RawMaxSizeOrDefault()})
+	t.Cleanup(func() { httpServer.Close() })
+	return listener.Addr().String()
+}
+
+// rawRoundTrip writes a request to a server and returns everything it responds with.
+func rawRoundTrip(t *testing.T, addr string, request []byte) []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	response, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestRawGzipBody(t *testing.T) {
+	addr := startRawServer(t, Config{})
+
+	contents := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(contents)
+	var body bytes.Buffer
+	writer := gzip.NewWriter(&body)
+	writer.Write(contents)
+	writer.Close()
+
+	request := append([]byte(fmt.Sprintf("POST /raw HTTP/1.1\r\nHost: echo\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n", body.Len())), body.Bytes()...)
+	response := rawRoundTrip(t, addr, request)
+	if !bytes.HasPrefix(response, []byte("HTTP/1.1 200 OK\r\n")) {
+		t.Fatalf("unexpected response: %q", response[:minInt(len(response), 200)])
+	}
+	if !bytes.HasSuffix(response, request) {
+		t.Fatalf("expected the whole %d byte request back, got a %d byte response", len(request), len(response))
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// scriptedConn is a connection that reads from a buffer and discards writes.
+type scriptedConn struct {
+	net.Conn
+	reader *bytes.Reader
+}
+
+func (sc *scriptedConn) Read(contents []byte) (int, error) { return sc.reader.Read(contents) }
+
+func (sc *scriptedConn) Write(contents []byte) (int, error) { return len(contents), nil }
+
+func TestRecordingConnStopsAfterHead(t *testing.T) {
+	head := "PUT /upload HTTP/1.1\r\nHost: echo\r\nContent-Length: 1048576\r\n\r\n"
+	body := bytes.Repeat([]byte("x"), 1<<20)
+	rc := &RecordingConn{Conn: &scriptedConn{reader: bytes.NewReader(append([]byte(head), body...))}, MaxSize: DefaultRawMaxSize}
+
+	buffer := make([]byte, 1000)
+	for {
+		if _, err := rc.Read(buffer); err != nil {
+			break
+		}
+	}
+	recorded, truncated := rc.Recording()
+	if truncated {
+		t.Fatal("expected the head not to be truncated")
+	}
+	if expected := len(head) + recordingReadAhead; len(recorded) != expected {
+		t.Fatalf("expected %d bytes recorded, got %d", expected, len(recorded))
+	}
+
+	// the next request is recorded from scratch after the response.
+	next := "GET /raw HTTP/1.1\r\nHost: echo\r\n\r\n"
+	rc.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+	rc.Conn = &scriptedConn{reader: bytes.NewReader([]byte(next))}
+	rc.Read(buffer)
+	if recorded, _ := rc.Recording(); string(recorded) != next {
+		t.Fatalf("expected the next request to be recorded, got %q", recorded)
+	}
+}
+
+func TestRecordingConnKeepsReadAhead(t *testing.T) {
+	requests := "GET /ip HTTP/1.1\r\nHost: echo\r\n\r\nGET /raw HTTP/1.1\nHost: echo\n\n"
+	rc := &RecordingConn{Conn: &scriptedConn{reader: bytes.NewReader([]byte(requests))}, MaxSize: DefaultRawMaxSize}
+	rc.Read(make([]byte, 4096))
+	rc.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+
+	recorded, _ := rc.Recording()
+	req, _ := http.NewRequest(http.MethodGet, "/raw", nil)
+	req.RequestURI = "/raw"
+	head, ok := findRequestHead(recorded, req)
+	if !ok || string(head) != "GET /raw HTTP/1.1\nHost: echo\n\n" {
+		t.Fatalf("expected the pipelined request's head, got %q (%v)", head, ok)
+	}
+}
+
+func TestFindRequestHead(t *testing.T) {
+	testCases := [...]struct {
+		Name     string
+		Recorded string
+		Method   string
+		URI      string
+		Expected string
+		OK       bool
+	}{
+		{Name: "whole", Recorded: "GET /raw HTTP/1.1\r\n\r\n", Method: "GET", URI: "/raw", Expected: "GET /raw HTTP/1.1\r\n\r\n", OK: true},
+		{Name: "after another request", Recorded: "body\r\nPOST /raw?a=1 HTTP/1.1\r\n\r\n", Method: "POST", URI: "/raw?a=1", Expected: "POST /raw?a=1 HTTP/1.1\r\n\r\n", OK: true},
+		{Name: "not at a line start", Recorded: "xGET /raw HTTP/1.1\r\n\r\n", Method: "GET", URI: "/raw"},
+		{Name: "in a header", Recorded: "GET /raw HTTP/1.1\r\nX: GET /raw HTTP/1.1\r\n\r\n", Method: "GET", URI: "/raw", Expected: "GET /raw HTTP/1.1\r\nX: GET /raw HTTP/1.1\r\n\r\n", OK: true},
+		{Name: "other uri", Recorded: "GET /raw2 HTTP/1.1\r\n\r\n", Method: "GET", URI: "/raw"},
+		{Name: "empty", Method: "GET", URI: "/raw"},
+	}
+	for _, tc := range testCases {
+		req := &http.Request{Method: tc.Method, RequestURI: tc.URI}
+		head, ok := findRequestHead([]byte(tc.Recorded), req)
+		if ok != tc.OK || string(head) != tc.Expected {
+			t.Errorf("%s: expected %q (%v), got %q (%v)", tc.Name, tc.Expected, tc.OK, head, ok)
+		}
+	}
+}
+
+func TestReadRawChunks(t *testing.T) {
+	testCases := [...]struct {
+		Name     string
+		Body     string
+		Limit    int64
+		Expected string
+		Err      bool
+		TooLarge bool
+	}{
+		{Name: "chunks", Body: "3\r\nabc\r\n2;ext=1\r\nde\r\n0\r\n\r\nnext", Limit: 100, Expected: "3\r\nabc\r\n2;ext=1\r\nde\r\n0\r\n\r\n"},
+		{Name: "trailers", Body: "1\r\na\r\n0\r\nX-Sum: 1\r\nX-Other: 2\r\n\r\n", Limit: 100, Expected: "1\r\na\r\n0\r\nX-Sum: 1\r\nX-Other: 2\r\n\r\n"},
+		{Name: "bare line feeds", Body: "1\na\n0\n\n", Limit: 100, Expected: "1\na\n0\n\n"},
+		{Name: "upper case hex", Body: "A\r\n0123456789\r\n0\r\n\r\n", Limit: 100, Expected: "A\r\n0123456789\r\n0\r\n\r\n"},
+		{Name: "invalid size", Body: "zz\r\nabc\r\n0\r\n\r\n", Limit: 100, Err: true},
+		{Name: "negative size", Body: "-1\r\n", Limit: 100, Err: true},
+		{Name: "truncated", Body: "5\r\nab", Limit: 100, Err: true},
+		{Name: "over the limit", Body: "10\r\n0123456789abcdef\r\n0\r\n\r\n", Limit: 10, Err: true, TooLarge: true},
+	}
+	for _, tc := range testCases {
+		body, err := readRawChunks(bufio.NewReader(strings.NewReader(tc.Body)), tc.Limit)
+		if tc.Err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.Name)
+			} else if tc.TooLarge && !ex.Is(err, ErrRawTooLarge) {
+				t.Errorf("%s: expected %v, got %v", tc.Name, ErrRawTooLarge, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+			continue
+		}
+		if string(body) != tc.Expected {
+			t.Errorf("%s: expected %q, got %q", tc.Name, tc.Expected, body)
+		}
+	}
+}
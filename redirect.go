@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+const (
+	// DefaultRedirectStatus is the redirect status used when `?status=` is unset.
+	DefaultRedirectStatus = http.StatusFound
+	// MaxRedirects is the longest redirect chain we'll issue.
+	MaxRedirects = 100
+)
+
+// RedirectStatuses are the redirect status codes we can issue.
+var RedirectStatuses = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// RedirectWithStatus returns a redirect result with a specific status code.
+func RedirectWithStatus(statusCode int, destination string) *StatusRedirectResult {
+	return &StatusRedirectResult{
+		RedirectResult: web.RedirectResult{RedirectURI: destination},
+		StatusCode:     statusCode,
+	}
+}
+
+// StatusRedirectResult is a `web.RedirectResult` with a specific status code.
+// `web.RedirectResult` only issues 302 (with a method) or 307.
+type StatusRedirectResult struct {
+	web.RedirectResult
+	StatusCode int `json:"status_code"`
+}
+
+// Render writes the result to the response.
+func (srr *StatusRedirectResult) Render(ctx *web.Ctx) error {
+	http.Redirect(ctx.Response, ctx.Request, srr.RedirectURI, srr.StatusCode)
+	return nil
+}
+
+// Redirects is a controller for redirect test endpoints.
+type Redirects struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (rd Redirects) Register(app *web.App) {
+	// every method is routed so clients' method preservation can be checked.
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.POST, app.PUT, app.PATCH, app.DELETE} {
+		register("/redirect/:n", rd.relative)
+		register("/absolute-redirect/:n", rd.absolute)
+		register("/redirect-to", rd.redirectTo)
+		register("/redirect-loop", rd.loop)
+	}
+}
+
+// RedirectDestination is returned at the end of a redirect chain so clients can check
+// which method and body survived the redirects.
+type RedirectDestination struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Headers       http.Header `json:"headers"`
+	ContentLength int64       `json:"contentLength"`
+}
+
+// relative redirects `n` times with relative locations, then returns the destination.
+func (rd Redirects) relative(r *web.Ctx) web.Result {
+	return rd.chain(r, "/redirect/%d", "")
+}
+
+// absolute redirects `n` times with absolute locations, then returns the destination.
+func (rd Redirects) absolute(r *web.Ctx) web.Result {
+	return rd.chain(r, "/absolute-redirect/%d", RequestBaseURL(r.Request))
+}
+
+// redirectTo redirects to `?url=` with `?status=`.
+// The url must be a local path, the request host, or a host allowed by `REDIRECT_ALLOWED_HOSTS`.
+func (rd Redirects) redirectTo(r *web.Ctx) web.Result {
+	statusCode, err := redirectStatus(r)
+	if err != nil {
+		return web.JSON.BadRequest(err)
+	}
+	destination := r.Request.URL.Query().Get("url")
+	if destination == "" {
+		return web.JSON.BadRequest(ex.New("missing `url` parameter"))
+	}
+	if !isLocalRedirect(destination) {
+		parsed, err := url.Parse(destination)
+		if err != nil || (parsed.Scheme != webutil.SchemeHTTP && parsed.Scheme != webutil.SchemeHTTPS) {
+			return web.JSON.BadRequest(ex.New("invalid `url`; must be a local path or an http(s) url", ex.OptMessagef("url: %s", destination)))
+		}
+		if !rd.Config.AllowsRedirectHost(parsed.Hostname()) && parsed.Host != r.Request.Host {
+			return web.JSON.Status(http.StatusForbidden, fmt.Sprintf("redirect host %q is not allowed", parsed.Hostname()))
+		}
+	}
+	return RedirectWithStatus(statusCode, destination)
+}
+
+// loop redirects to itself forever.
+func (rd Redirects) loop(r *web.Ctx) web.Result {
+	statusCode, err := redirectStatus(r)
+	if err != nil {
+		return web.JSON.BadRequest(err)
+	}
+	return RedirectWithStatus(statusCode, r.Request.URL.RequestURI())
+}
+
+// chain issues the next redirect of a chain, preserving the query string (and so `?status=`).
+func (rd Redirects) chain(r *web.Ctx, pathFormat, baseURL string) web.Result {
+	n, err := web.IntValue(r.RouteParam("n"))
+	if err != nil || n < 0 || n > MaxRedirects {
+		return web.JSON.BadRequest(ex.New("invalid redirect count", ex.OptMessagef("n must be between 0 and %d", MaxRedirects)))
+	}
+	if n == 0 {
+		return Negotiated(r, FormatJSON, RedirectDestination{
+			Method:        r.Request.Method,
+			URL:           r.Request.URL.RequestURI(),
+			Headers:       r.Request.Header,
+			ContentLength: r.Request.ContentLength,
+		})
+	}
+	statusCode, err := redirectStatus(r)
+	if err != nil {
+		return web.JSON.BadRequest(err)
+	}
+	destination := baseURL + fmt.Sprintf(pathFormat, n-1)
+	if r.Request.URL.RawQuery != "" {
+		destination += "?" + r.Request.URL.RawQuery
+	}
+	return RedirectWithStatus(statusCode, destination)
+}
+
+// redirectStatus reads the `?status=` redirect status code.
+func redirectStatus(r *web.Ctx) (int, error) {
+	value := r.Request.URL.Query().Get("status")
+	if value == "" {
+		return DefaultRedirectStatus, nil
+	}
+	statusCode, err := web.IntValue(value, nil)
+	if err != nil || !RedirectStatuses[statusCode] {
+		return 0, ex.New("invalid redirect status; must be one of 301, 302, 303, 307 or 308", ex.OptMessagef("status: %s", value))
+	}
+	return statusCode, nil
+}
+
+// RequestBaseURL returns the scheme and host a request was made to, e.g. `https://echo.example.com`.
+func RequestBaseURL(req *http.Request) string {
+	scheme := webutil.GetProto(req)
+	if scheme == "" {
+		scheme = webutil.SchemeHTTP
+		if req.TLS != nil {
+			scheme = webutil.SchemeHTTPS
+		}
+	}
+	return scheme + "://" + req.Host
+}
+
+// hostMatches returns if a host matches a pattern; either exact or a `*.` suffix wildcard.
+func hostMatches(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// Responses is a controller for endpoints whose responses are dictated by the caller.
+//
+// They bypass the http middleware and drop the app's default headers,
+// so the response is what was asked for (plus what net/http insists on, e.g. framing).
+type Responses struct{}
+
+// Register implements web.Controller.
+func (rs Responses) Register(app *web.App) {
+	for _, register := range []func(string, web.Action, ...web.Middleware){app.GET, app.HEAD, app.POST, app.PUT, app.PATCH, app.DELETE} {
+		register("/response-headers", rs.responseHeaders)
+		register("/response", rs.response)
+	}
+}
+
+// responseHeaders adds a response header for each query parameter (repeated parameters add repeated values)
+// and returns the headers as json.
+func (rs Responses) responseHeaders(r *web.Ctx) web.Result {
+	header := rs.reset(r)
+	for name, values := range r.Request.URL.Query() {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	if _, ok := header[web.HeaderContentType]; !ok {
+		header.Set(web.HeaderContentType, web.ContentTypeApplicationJSON)
+	}
+
+	contents, err := json.Marshal(header)
+	if err != nil {
+		return web.JSON.InternalError(err)
+	}
+	r.Response.WriteHeader(http.StatusOK)
+	r.Response.Write(contents)
+	return nil
+}
+
+// response writes a response from query parameters:
+//
+//	status       the status code (default 200)
+//	contentType  the `Content-Type`; without it none is sent (no sniffing)
+//	body         the body as text
+//	bodyBase64   the body as base64 (standard or url encoding, padded or not)
+//	headers      a header as `Name: Value`, repeatable; `Date` and `Content-Length` are respected
+//
+// Without a `Date` header none is sent.
+func (rs Responses) response(r *web.Ctx) web.Result {
+	query := r.Request.URL.Query()
+
+	statusCode := http.StatusOK
+	if value := query.Get("status"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 200 || parsed > 999 {
+			return web.JSON.BadRequest(ex.New("invalid status; must be an integer from 200 to 999", ex.OptMessagef("status: %s", value)))
+		}
+		statusCode = parsed
+	}
+
+	var body []byte
+	if _, ok := query["body"]; ok {
+		body = []byte(query.Get("body"))
+	}
+	if value, ok := query["bodyBase64"]; ok {
+		if body != nil {
+			return web.JSON.BadRequest(ex.New("only one of `body` and `bodyBase64` may be set"))
+		}
+		decoded, err := decodeBase64(value[0])
+		if err != nil {
+			return web.JSON.BadRequest(ex.New("invalid bodyBase64", ex.OptInner(err)))
+		}
+		body = decoded
+	}
+
+	var headers []string
+	for _, value := range query["headers"] {
+		colon := strings.Index(value, ":")
+		if colon <= 0 {
+			return web.JSON.BadRequest(ex.New("invalid header; must be `Name: Value`", ex.OptMessagef("header: %s", value)))
+		}
+		headers = append(headers, strings.TrimSpace(value[:colon]), strings.TrimSpace(value[colon+1:]))
+	}
+
+	header := rs.reset(r)
+	header[web.HeaderContentType] = nil
+	header["Date"] = nil
+	if contentType := query.Get("contentType"); contentType != "" {
+		header.Set(web.HeaderContentType, contentType)
+	}
+	for index := 0; index < len(headers); index += 2 {
+		name := textproto.CanonicalMIMEHeaderKey(headers[index])
+		// a header given nil suppresses net/http's default, so replace it rather than add to it.
+		if existing, ok := header[name]; ok && existing == nil {
+			delete(header, name)
+		}
+		header.Add(name, headers[index+1])
+	}
+
+	r.Response.WriteHeader(statusCode)
+	if len(body) > 0 {
+		r.Response.Write(body)
+	}
+	return nil
+}
+
+// reset takes the response out of the http middleware and clears the app's default headers.
+func (rs Responses) reset(r *web.Ctx) http.Header {
+	Passthrough(r.Request)
+	header := r.Response.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	// with the compression middleware disabled the app gzips the response itself, which can't be undone.
+	if _, ok := r.Response.(*web.CompressedResponseWriter); ok {
+		header.Set(web.HeaderContentEncoding, web.ContentEncodingGZIP)
+	}
+	return header
+}
+
+// decodeBase64 decodes standard or url encoded base64, padded or not.
+// A `+` left unescaped in a query reads as a space, so spaces are read as `+`.
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.ReplaceAll(strings.TrimRight(value, "="), " ", "+")
+	if strings.ContainsAny(value, "-_") {
+		return base64.RawURLEncoding.DecodeString(value)
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+// HTTPMiddleware wraps an http handler.
+// Unlike `web.Middleware` it runs before the app picks a response writer,
+// so it can change how the response is written (e.g. compression).
+type HTTPMiddleware func(http.Handler) http.Handler
+
+// passthroughWriter is implemented by the http middleware response writers that change responses.
+type passthroughWriter interface {
+	// Passthrough stops the writer changing the response.
+	Passthrough()
+}
+
+type passthroughKey struct{}
+
+// withPassthrough registers a response writer with the request for `Passthrough`.
+func withPassthrough(req *http.Request, writer passthroughWriter) *http.Request {
+	if writers, ok := req.Context().Value(passthroughKey{}).(*[]passthroughWriter); ok {
+		*writers = append(*writers, writer)
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), passthroughKey{}, &[]passthroughWriter{writer}))
+}
+
+// Passthrough stops http middleware (e.g. compression and etags) changing the response to a request,
+// for handlers that must send exactly what they write. It must be called before anything is written.
+func Passthrough(req *http.Request) {
+	if writers, ok := req.Context().Value(passthroughKey{}).(*[]passthroughWriter); ok {
+		for _, writer := range *writers {
+			writer.Passthrough()
+		}
+	}
+}
+
+type hijackerKey struct{}
+
+// Hijack takes over a request's connection like `http.Hijacker`, whatever response writers
+// the app and middleware have wrapped around it (not all of which can hijack).
+func Hijack(req *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := req.Context().Value(hijackerKey{}).(http.Hijacker)
+	if !ok {
+		return nil, nil, ex.New(http.ErrNotSupported)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, ex.New(err)
+	}
+	return conn, rw, nil
+}
+
+// NewServer returns a new server for an app with http middleware applied around it.
+// Middleware are applied in order, so the first is outermost.
+func NewServer(app *web.App, middleware ...HTTPMiddleware) *Server {
+	return &Server{
+		App:        app,
+		Middleware: middleware,
+	}
+}
+
+// Server serves an app like `(*web.App).Start`, but lets us wrap the app's handler,
+// which `Start` always sets to the app itself, and its listener.
+// Stopping and lifecycle notifications are the app's own.
+type Server struct {
+	*web.App
+	Middleware []HTTPMiddleware
+	// ProxyProtocol reads PROXY protocol headers from connections, if it's enabled.
+	ProxyProtocol *ProxyProtocol
+	// Connections tracks the server's connections, if it's set.
+	Connections *ConnTracker
+	// RawMaxSize is the most of each request connections record for `/raw`.
+	RawMaxSize int64
+}
+
+// Handler returns the app wrapped in the middleware.
+func (s *Server) Handler() http.Handler {
+	var handler http.Handler = s.App
+	for index := len(s.Middleware) - 1; index >= 0; index-- {
+		handler = s.Middleware[index](handler)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			req = req.WithContext(context.WithValue(req.Context(), hijackerKey{}, hijacker))
+		}
+		s.Connections.Request(w, req)
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// ConnContext adds what we know about a connection to the context of its requests;
+// it's the server's `ConnContext`.
+func (s *Server) ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	ctx = WithProxyProtocolConn(ctx, conn)
+	ctx = WithRecordingConn(ctx, conn)
+	ctx = s.Connections.ConnContext(ctx, conn)
+	return ctx
+}
+
+// Start starts the server and binds to the configured address.
+func (s *Server) Start() (err error) {
+	a := s.App
+	a.Server = a.CreateServer()
+	a.Server.Handler = s.Handler()
+	a.Server.ConnContext = s.ConnContext
+	a.Server.ConnState = s.Connections.ConnState
+
+	if err = a.StartupTasks(); err != nil {
+		return
+	}
+
+	serverProtocol := "http"
+	if a.Server.TLSConfig != nil {
+		serverProtocol = "https (tls)"
+	}
+	logger.MaybeInfof(a.Log, "%s server started, listening on %s", serverProtocol, a.Config.BindAddrOrDefault())
+
+	var listener net.Listener
+	listener, err = net.Listen("tcp", a.Config.BindAddrOrDefault())
+	if err != nil {
+		err = ex.New(err)
+		return
+	}
+	var ok bool
+	if a.Listener, ok = listener.(*net.TCPListener); !ok {
+		err = ex.New("listener returned was not a net.TCPListener")
+		return
+	}
+	listener = s.ProxyProtocol.Listener(web.TCPKeepAliveListener{TCPListener: a.Listener})
+	if a.Server.TLSConfig != nil {
+		listener = tls.NewListener(listener, a.Server.TLSConfig)
+	} else {
+		// recording under tls would only see ciphertext, and hide the tls connection from the server.
+		listener = &RecordingListener{Listener: listener, MaxSize: s.RawMaxSize}
+	}
+
+	a.Started()
+	if shutdownErr := a.Server.Serve(listener); shutdownErr != nil && shutdownErr != http.ErrServerClosed {
+		err = ex.New(shutdownErr)
+	}
+	logger.MaybeInfof(a.Log, "server exited")
+	a.Stopped()
+	return
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blend/go-sdk/env"
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/logger"
+	"github.com/blend/go-sdk/web"
+)
+
+// ResolveWebConfig reads the web config from the environment and applies the session mode.
+// In jwt mode without an `AUTH_SECRET` a random secret is generated, so sessions don't survive restarts.
+func ResolveWebConfig(cfg Config, log logger.Log) (web.Config, error) {
+	var webCfg web.Config
+	if err := env.Env().ReadInto(&webCfg); err != nil {
+		return webCfg, ex.New(err)
+	}
+
+	switch mode := cfg.AuthManagerModeOrDefault(); mode {
+	case web.AuthManagerModeLocal:
+	case web.AuthManagerModeJWT:
+		if webCfg.AuthSecret == "" {
+			secret := make([]byte, 64)
+			if _, err := rand.Read(secret); err != nil {
+				return webCfg, ex.New(err)
+			}
+			webCfg.AuthSecret = base64.StdEncoding.EncodeToString(secret)
+			logger.MaybeWarningf(log, "session mode is jwt but AUTH_SECRET is unset; using a random secret, sessions will not survive a restart")
+		}
+		if _, err := base64.StdEncoding.DecodeString(webCfg.AuthSecret); err != nil {
+			return webCfg, ex.New("invalid AUTH_SECRET; must be base64 encoded", ex.OptInner(err))
+		}
+	default:
+		return webCfg, ex.New("invalid auth manager mode; must be `local` or `jwt`", ex.OptMessagef("mode: %s", mode))
+	}
+	webCfg.AuthManagerMode = string(cfg.AuthManagerModeOrDefault())
+	return webCfg, nil
+}
+
+// SessionDemo is a controller for a cookie session login flow built on the app's auth manager.
+// It reports the cookie attributes and expiry it issues, for debugging how proxies treat session cookies.
+type SessionDemo struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (sd SessionDemo) Register(app *web.App) {
+	app.Auth.LoginRedirectHandler = sd.loginRedirect
+
+	app.GET("/session/login", sd.login)
+	app.POST("/session/login", sd.login)
+	app.GET("/session/logout", sd.logout)
+	app.POST("/session/logout", sd.logout)
+	app.GET("/session/whoami", sd.whoami, web.SessionAware)
+	app.GET("/session/private", sd.private, web.SessionRequired)
+}
+
+// SessionInfo describes the session state of a request.
+type SessionInfo struct {
+	Mode          string            `json:"mode"`
+	Authenticated bool              `json:"authenticated"`
+	UserID        string            `json:"userID,omitempty"`
+	SessionID     string            `json:"sessionID,omitempty"`
+	Created       *time.Time        `json:"created,omitempty"`
+	Expires       *time.Time        `json:"expires,omitempty"`
+	ExpiresIn     string            `json:"expiresIn,omitempty"`
+	Cookie        SessionCookieInfo `json:"cookie"`
+	CookieSent    bool              `json:"cookieSent"`
+	SetCookie     []string          `json:"setCookie,omitempty"`
+}
+
+// SessionCookieInfo are the attributes of the session cookies the auth manager issues.
+type SessionCookieInfo struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"httpOnly"`
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+// login logs in the `user` (query or form) and redirects to `?redirect=` if set.
+func (sd SessionDemo) login(r *web.Ctx) web.Result {
+	user := r.Request.FormValue("user")
+	if user == "" {
+		return web.JSON.BadRequest(ex.New("missing `user` parameter"))
+	}
+	session, err := r.Auth.Login(user, r)
+	if err != nil {
+		return web.JSON.InternalError(err)
+	}
+	r.Session = session
+	if redirect := r.Request.FormValue("redirect"); isLocalRedirect(redirect) {
+		return web.RedirectWithMethod(http.MethodGet, redirect)
+	}
+	return Negotiated(r, FormatJSON, sd.info(r))
+}
+
+func (sd SessionDemo) logout(r *web.Ctx) web.Result {
+	if err := r.Auth.Logout(r); err != nil {
+		return web.JSON.InternalError(err)
+	}
+	return Negotiated(r, FormatJSON, sd.info(r))
+}
+
+func (sd SessionDemo) whoami(r *web.Ctx) web.Result {
+	info := sd.info(r)
+	if !info.Authenticated {
+		return NegotiatedStatus(r, FormatJSON, http.StatusUnauthorized, info)
+	}
+	return Negotiated(r, FormatJSON, info)
+}
+
+func (sd SessionDemo) private(r *web.Ctx) web.Result {
+	return web.Text.Result("hello " + r.Session.UserID)
+}
+
+// loginRedirect sends unauthenticated requests for protected pages to the login route,
+// which sends them back once logged in.
+func (sd SessionDemo) loginRedirect(r *web.Ctx) *url.URL {
+	return &url.URL{
+		Path:     "/session/login",
+		RawQuery: url.Values{"redirect": []string{r.Request.URL.RequestURI()}}.Encode(),
+	}
+}
+
+func (sd SessionDemo) info(r *web.Ctx) SessionInfo {
+	info := SessionInfo{
+		Mode: string(sd.Config.AuthManagerModeOrDefault()),
+		Cookie: SessionCookieInfo{
+			Name:     r.Auth.CookieNameOrDefault(),
+			Path:     r.Auth.CookiePathOrDefault(),
+			Secure:   r.Auth.CookieSecure,
+			HTTPOnly: r.Auth.CookieHTTPOnly,
+			SameSite: r.Auth.CookieSameSite,
+		},
+		CookieSent: r.Cookie(r.Auth.CookieNameOrDefault()) != nil,
+		SetCookie:  r.Response.Header()["Set-Cookie"],
+	}
+	if r.Session != nil {
+		info.Authenticated = true
+		info.UserID = r.Session.UserID
+		info.SessionID = r.Session.SessionID
+		info.Created = &r.Session.CreatedUTC
+		if !r.Session.ExpiresUTC.IsZero() {
+			info.Expires = &r.Session.ExpiresUTC
+			info.ExpiresIn = time.Until(r.Session.ExpiresUTC).Round(time.Second).String()
+		}
+	}
+	return info
+}
+
+// isLocalRedirect returns if a redirect target is a path on this host.
+//
+// Browsers drop tabs and newlines from urls and treat backslashes as slashes, so `/\t/evil.com`
+// is followed as `//evil.com`; targets with whitespace or control characters aren't local.
+func isLocalRedirect(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return false
+	}
+	for _, c := range target {
+		if c <= ' ' || c == 0x7f {
+			return false
+		}
+	}
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestIsLocalRedirect(t *testing.T) {
+	testCases := [...]struct {
+		Target   string
+		Expected bool
+	}{
+		{Target: "/", Expected: true},
+		{Target: "/private", Expected: true},
+		{Target: "/private?next=/a#b", Expected: true},
+		{Target: "/a//b", Expected: true},
+		{Target: "/%2F%2Fevil.com", Expected: true},
+		{Target: ""},
+		{Target: "private"},
+		{Target: "//evil.com"},
+		{Target: "/\\evil.com"},
+		{Target: "https://evil.com"},
+		{Target: "javascript:alert(1)"},
+		{Target: "/\t/evil.com"},
+		{Target: "/\n/evil.com"},
+		{Target: "/\r\n/evil.com"},
+		{Target: "/ /evil.com"},
+		{Target: "/\x00/evil.com"},
+		{Target: "/\x7f/evil.com"},
+		{Target: "/private\r\nSet-Cookie: a=b"},
+	}
+	for _, tc := range testCases {
+		if actual := isLocalRedirect(tc.Target); actual != tc.Expected {
+			t.Errorf("%q: expected %v, got %v", tc.Target, tc.Expected, actual)
+		}
+	}
+}
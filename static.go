@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/blend/go-sdk/web"
+)
+
+// StaticRoute is the route static files are served under.
+const StaticRoute = "/static/*filepath"
+
+// Static is a controller that serves files from the configured static directory, if there is one.
+//
+// Files are served by a `web.StaticFileServer`, which supports `Range` and `If-Range` through
+// `http.ServeContent`; we add an etag (from the modification time and size, like nginx)
+// so `If-Range` can be sent with an etag as well as a date.
+type Static struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (s Static) Register(app *web.App) {
+	if s.Config.StaticDir == "" {
+		return
+	}
+	sfs := web.NewStaticFileServer(http.Dir(s.Config.StaticDir))
+	sfs.CacheDisabled = !s.Config.StaticCached
+	app.Statics[StaticRoute] = sfs
+
+	action := func(r *web.Ctx) web.Result {
+		filePath, err := r.RouteParam("filepath")
+		if err != nil {
+			return web.Text.BadRequest(err)
+		}
+		// headers added with `app.SetStaticHeader`, as the file server's own action sets them.
+		for key, values := range sfs.Headers {
+			for _, value := range values {
+				r.Response.Header().Set(key, value)
+			}
+		}
+		file, err := sfs.ResolveFile(filePath)
+		if file == nil || (err != nil && os.IsNotExist(err)) {
+			return web.Text.NotFound()
+		}
+		if err != nil {
+			return web.Text.InternalError(err)
+		}
+		info, err := file.Stat()
+		file.Close()
+		if err != nil {
+			return web.Text.InternalError(err)
+		}
+		if info.IsDir() {
+			return web.Text.NotFound()
+		}
+
+		if sfs.CacheDisabled {
+			r.Response.Header().Set(HeaderETag, fmt.Sprintf(`"%x-%x"`, info.ModTime().Unix(), info.Size()))
+			clearIdentityEncoding(r)
+			return sfs.ServeFile(r, filePath)
+		}
+
+		// the cache keeps the file as it was first read, which may not be what's on disk now.
+		cached, err := sfs.ResolveCachedFile(filePath)
+		if err != nil {
+			return web.Text.InternalError(err)
+		}
+		r.Response.Header().Set(HeaderETag, fmt.Sprintf(`"%x-%x"`, cached.ModTime.Unix(), cached.Size))
+		clearIdentityEncoding(r)
+		// the cached file's reader is shared between requests, which would race on its offset
+		// (as `ServeCachedFile` uses it), so each request reads through its own section of it.
+		http.ServeContent(r.Response, r.Request, filePath, cached.ModTime, io.NewSectionReader(cached.Contents, 0, int64(cached.Size)))
+		return nil
+	}
+	app.GET(StaticRoute, action)
+	app.HEAD(StaticRoute, action)
+}
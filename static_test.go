@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blend/go-sdk/web"
+)
+
+func TestStaticHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "echo-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("static contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cached := range []bool{false, true} {
+		app := web.New()
+		app.Register(Static{Config: Config{StaticDir: dir, StaticCached: cached}})
+		if err := app.SetStaticHeader(StaticRoute, "X-Static", "yes"); err != nil {
+			t.Fatal(err)
+		}
+
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/static/a.txt", nil))
+		if res.Code != http.StatusOK || res.Body.String() != "static contents" {
+			t.Fatalf("cached %v: unexpected response %d: %q", cached, res.Code, res.Body.String())
+		}
+		if value := res.Header().Get("X-Static"); value != "yes" {
+			t.Errorf("cached %v: expected the static header, got %q", cached, value)
+		}
+		if res.Header().Get(HeaderETag) == "" {
+			t.Errorf("cached %v: expected an etag", cached)
+		}
+	}
+}
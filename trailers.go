@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+)
+
+// HeaderTrailer is the `Trailer` header, which declares the trailers a message will have.
+const HeaderTrailer = "Trailer"
+
+// DefaultTrailerBody is the body trailers are sent after if none is given.
+const DefaultTrailerBody = "trailers follow\n"
+
+// Trailers is a controller for endpoints that send response trailers and echo request trailers.
+type Trailers struct{}
+
+// Register implements web.Controller.
+func (t Trailers) Register(app *web.App) {
+	app.GET("/trailers", t.trailers)
+	app.POST("/trailers/echo", t.echo)
+	app.PUT("/trailers/echo", t.echo)
+}
+
+// trailers sends a chunked body (the `body` query parameter, or a default) followed by trailers;
+// each `trailer` query parameter as `Name: Value`, or `X-Body-SHA256` with the body's hash if there are none.
+// The trailers are declared in the `Trailer` header unless `undeclared` is set.
+//
+// The response bypasses the http middleware, which could otherwise buffer it and drop the trailers.
+func (t Trailers) trailers(r *web.Ctx) web.Result {
+	query := r.Request.URL.Query()
+	body := DefaultTrailerBody
+	if _, ok := query["body"]; ok {
+		body = query.Get("body")
+	}
+
+	var trailers []string
+	for _, value := range query["trailer"] {
+		colon := strings.Index(value, ":")
+		if colon <= 0 {
+			return web.Text.BadRequest(ex.New("invalid trailer; must be `Name: Value`", ex.OptMessagef("trailer: %s", value)))
+		}
+		trailers = append(trailers, textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(value[:colon])), strings.TrimSpace(value[colon+1:]))
+	}
+	if len(trailers) == 0 {
+		hash := sha256.Sum256([]byte(body))
+		trailers = []string{"X-Body-Sha256", hex.EncodeToString(hash[:])}
+	}
+	_, undeclared := query["undeclared"]
+
+	Passthrough(r.Request)
+	header := r.Response.Header()
+	header.Set(web.HeaderContentType, web.ContentTypeText)
+	if !undeclared {
+		for index := 0; index < len(trailers); index += 2 {
+			header.Add(HeaderTrailer, trailers[index])
+		}
+	}
+	r.Response.WriteHeader(http.StatusOK)
+	io.WriteString(r.Response, body)
+	// flushing before the end of the response makes it chunked, which trailers need.
+	r.Response.Flush()
+	for index := 0; index < len(trailers); index += 2 {
+		name := trailers[index]
+		if undeclared {
+			name = http.TrailerPrefix + name
+		}
+		header.Add(name, trailers[index+1])
+	}
+	return nil
+}
+
+// TrailersEchoResponse describes a request's trailers.
+type TrailersEchoResponse struct {
+	// Declared are the trailers the request declared in its `Trailer` header.
+	Declared []string `json:"declared"`
+	// Trailers are the trailers that were received.
+	Trailers http.Header `json:"trailers"`
+	BodySize int64       `json:"bodySize"`
+	Chunked  bool        `json:"chunked"`
+}
+
+// echo reads a request's body and returns the trailers it was sent with.
+// Trailers arrive after the body, so they can only be read once the body has been.
+func (t Trailers) echo(r *web.Ctx) web.Result {
+	var response TrailersEchoResponse
+	for name := range r.Request.Trailer {
+		response.Declared = append(response.Declared, name)
+	}
+	sort.Strings(response.Declared)
+
+	size, err := io.Copy(ioutil.Discard, r.Request.Body)
+	if err != nil {
+		return web.Text.BadRequest(err)
+	}
+	response.BodySize = size
+	response.Chunked = len(r.Request.TransferEncoding) > 0 && r.Request.TransferEncoding[0] == "chunked"
+	response.Trailers = http.Header{}
+	for name, values := range r.Request.Trailer {
+		if len(values) > 0 {
+			response.Trailers[name] = values
+		}
+	}
+	return Negotiated(r, FormatJSON, response)
+}
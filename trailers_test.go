@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blend/go-sdk/web"
+)
+
+func TestTrailers(t *testing.T) {
+	app := web.New(web.OptUse(RestoreAcceptEncoding))
+	app.Register(Trailers{})
+	addr := startServer(t, NewServer(app, Compress(Config{})))
+
+	hash := sha256.Sum256([]byte(DefaultTrailerBody))
+	testCases := [...]struct {
+		Query    string
+		Body     string
+		Declared []string
+		Trailers http.Header
+	}{
+		{Body: DefaultTrailerBody, Declared: []string{"X-Body-Sha256"}, Trailers: http.Header{"X-Body-Sha256": {hex.EncodeToString(hash[:])}}},
+		{Query: "body=hello&trailer=x-one:+1&trailer=X-Two:2", Body: "hello", Declared: []string{"X-One", "X-Two"}, Trailers: http.Header{"X-One": {"1"}, "X-Two": {"2"}}},
+		{Query: "undeclared&trailer=X-One:1", Body: DefaultTrailerBody, Trailers: http.Header{"X-One": {"1"}}},
+	}
+	for _, tc := range testCases {
+		res, err := http.Get("http://" + addr + "/trailers?" + tc.Query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var declared []string
+		for name := range res.Trailer {
+			declared = append(declared, name)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tc.Body || len(res.TransferEncoding) == 0 || res.TransferEncoding[0] != "chunked" {
+			t.Errorf("%q: expected chunked body %q, got %q %q", tc.Query, tc.Body, res.TransferEncoding, body)
+		}
+		sort.Strings(declared)
+		if strings.Join(declared, ",") != strings.Join(tc.Declared, ",") {
+			t.Errorf("%q: expected declared trailers %q, got %q", tc.Query, tc.Declared, declared)
+		}
+		// trailers are only filled in once the body has been read.
+		for name, values := range tc.Trailers {
+			if actual := res.Trailer.Get(name); actual != values[0] {
+				t.Errorf("%q: expected trailer %s: %q, got %q", tc.Query, name, values[0], actual)
+			}
+		}
+	}
+
+	res, err := http.Get("http://" + addr + "/trailers?trailer=invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid trailer to be a bad request, got %d", res.StatusCode)
+	}
+}
+
+func TestTrailersEcho(t *testing.T) {
+	app := web.New(web.OptUse(RestoreAcceptEncoding))
+	app.Register(Trailers{})
+	addr := startServer(t, NewServer(app, Compress(Config{})))
+
+	// a body of unknown length is sent chunked, and trailers set on the request are sent after it.
+	body, writer := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/trailers/echo", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Trailer = http.Header{"X-Checksum": nil}
+	go func() {
+		io.WriteString(writer, "hello ")
+		io.WriteString(writer, "world")
+		req.Trailer.Set("X-Checksum", "abc123")
+		writer.Close()
+	}()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var response TrailersEchoResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Chunked || response.BodySize != 11 || strings.Join(response.Declared, ",") != "X-Checksum" || response.Trailers.Get("X-Checksum") != "abc123" {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
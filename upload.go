@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/fileutil"
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/webutil"
+)
+
+const (
+	// DefaultUploadMaxFileSize is the largest single uploaded file (or part).
+	DefaultUploadMaxFileSize = 512 * fileutil.Megabyte
+	// DefaultUploadMaxTotalSize is the largest total size of an upload's files (or parts).
+	DefaultUploadMaxTotalSize = 1024 * fileutil.Megabyte
+	// uploadSniffLength is how much of a file is used to detect its content type, as with `http.DetectContentType`.
+	uploadSniffLength = 512
+)
+
+// ErrUploadTooLarge is returned when an upload exceeds the file or total size limit.
+const ErrUploadTooLarge ex.Class = "upload too large"
+
+// Upload is a controller for the upload endpoint.
+//
+// Unlike `Ctx.PostedFiles`, which reads whole files into memory, uploads are streamed
+// to the upload directory part by part and hashed as they're written.
+type Upload struct {
+	Config Config
+}
+
+// Register implements web.Controller.
+func (u Upload) Register(app *web.App) {
+	app.POST("/upload", u.upload)
+	app.PUT("/upload", u.upload)
+}
+
+// UploadResponse describes an upload.
+type UploadResponse struct {
+	Parts     []UploadedPart `json:"parts"`
+	TotalSize int64          `json:"totalSize"`
+}
+
+// UploadedPart describes a multipart part, or a raw body.
+type UploadedPart struct {
+	Field string `json:"field,omitempty"`
+	// FileName is the part's filename, or for a raw body, from `?filename=` or `Content-Disposition`.
+	FileName string `json:"fileName,omitempty"`
+	// ContentType is the content type the client declared.
+	ContentType string `json:"contentType,omitempty"`
+	// DetectedContentType is from the file extension if it's well known, or the content otherwise.
+	DetectedContentType string `json:"detectedContentType"`
+	Size                int64  `json:"size"`
+	SHA256              string `json:"sha256"`
+	MD5                 string `json:"md5"`
+	// Path is where the part was written, if uploads are kept.
+	Path string `json:"path,omitempty"`
+}
+
+func (u Upload) upload(r *web.Ctx) web.Result {
+	var response UploadResponse
+	var paths []string
+	if !u.Config.UploadKeepFiles {
+		defer func() {
+			for _, path := range paths {
+				os.Remove(path)
+			}
+		}()
+	}
+
+	remaining := u.Config.UploadMaxTotalSizeOrDefault()
+	save := func(part UploadedPart, body io.Reader) error {
+		saved, err := u.save(part, body, remaining)
+		if saved.Path != "" {
+			paths = append(paths, saved.Path)
+		}
+		if err != nil {
+			return err
+		}
+		if !u.Config.UploadKeepFiles {
+			saved.Path = ""
+		}
+		remaining -= saved.Size
+		response.TotalSize += saved.Size
+		response.Parts = append(response.Parts, saved)
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Request.Header.Get(web.HeaderContentType))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader, err := r.Request.MultipartReader()
+		if err != nil {
+			return web.JSON.BadRequest(err)
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return u.error(err)
+			}
+			err = save(UploadedPart{
+				Field:       part.FormName(),
+				FileName:    part.FileName(),
+				ContentType: part.Header.Get(web.HeaderContentType),
+			}, part)
+			part.Close()
+			if err != nil {
+				return u.error(err)
+			}
+		}
+		return Negotiated(r, FormatJSON, response)
+	}
+
+	if limit := u.maxFileSize(remaining); r.Request.ContentLength > limit {
+		return u.error(ex.New(ErrUploadTooLarge, ex.OptMessagef("content length: %d, limit: %d", r.Request.ContentLength, limit)))
+	}
+	fileName := r.Request.URL.Query().Get("filename")
+	if fileName == "" {
+		if _, params, err := mime.ParseMediaType(r.Request.Header.Get("Content-Disposition")); err == nil {
+			fileName = params["filename"]
+		}
+	}
+	if err := save(UploadedPart{FileName: fileName, ContentType: r.Request.Header.Get(web.HeaderContentType)}, r.Request.Body); err != nil {
+		return u.error(err)
+	}
+	return Negotiated(r, FormatJSON, response)
+}
+
+// save streams a part to a temp file, hashing and sniffing it on the way.
+// The part's size is limited to the smaller of the file limit and the remaining total.
+func (u Upload) save(part UploadedPart, body io.Reader, remaining int64) (UploadedPart, error) {
+	file, err := ioutil.TempFile(u.Config.UploadDirOrDefault(), "upload-*"+filepath.Ext(filepath.Base(part.FileName)))
+	if err != nil {
+		return part, ex.New(err)
+	}
+	defer file.Close()
+	part.Path = file.Name()
+
+	sha256Hash, md5Hash := sha256.New(), md5.New()
+	sniffer := &uploadSniffer{}
+	limit := u.maxFileSize(remaining)
+	part.Size, err = io.Copy(io.MultiWriter(file, sha256Hash, md5Hash, sniffer), io.LimitReader(body, limit+1))
+	if err != nil {
+		return part, ex.New(err)
+	}
+	if part.Size > limit {
+		return part, ex.New(ErrUploadTooLarge, ex.OptMessagef("file: %q, file limit: %d, total limit: %d", part.FileName, u.Config.UploadMaxFileSizeOrDefault(), u.Config.UploadMaxTotalSizeOrDefault()))
+	}
+
+	part.SHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	part.MD5 = hex.EncodeToString(md5Hash.Sum(nil))
+	part.DetectedContentType = detectUploadContentType(part.FileName, sniffer.contents)
+	return part, nil
+}
+
+// maxFileSize is the largest the next part may be.
+func (u Upload) maxFileSize(remaining int64) int64 {
+	if limit := u.Config.UploadMaxFileSizeOrDefault(); limit < remaining {
+		return limit
+	}
+	return remaining
+}
+
+func (u Upload) error(err error) web.Result {
+	if ex.Is(err, ErrUploadTooLarge) {
+		return web.JSON.Status(http.StatusRequestEntityTooLarge, fmt.Sprintf("%v; %s", ErrUploadTooLarge, ex.ErrMessage(err)))
+	}
+	// a decompressed body can also hit the request decompression limit.
+	if typed := ex.As(err); typed != nil {
+		if _, ok := typed.Class.(*http.MaxBytesError); ok {
+			return web.JSON.Status(http.StatusRequestEntityTooLarge, typed.Class.Error())
+		}
+	}
+	return web.JSON.BadRequest(err)
+}
+
+// detectUploadContentType detects a content type like `webutil.DetectContentType`,
+// from a well known extension or otherwise the start of the contents.
+func detectUploadContentType(fileName string, contents []byte) string {
+	if contentType, ok := webutil.KnownExtensions[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return contentType
+	}
+	return http.DetectContentType(contents)
+}
+
+// uploadSniffer keeps the start of what's written to it.
+type uploadSniffer struct {
+	contents []byte
+}
+
+func (us *uploadSniffer) Write(contents []byte) (int, error) {
+	if missing := uploadSniffLength - len(us.contents); missing > 0 {
+		if len(contents) < missing {
+			missing = len(contents)
+		}
+		us.contents = append(us.contents, contents[:missing]...)
+	}
+	return len(contents), nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/blend/go-sdk/ex"
+	"github.com/blend/go-sdk/web"
+	"github.com/blend/go-sdk/yaml"
+)
+
+// ContentTypeYAML is the content type for yaml responses.
+const ContentTypeYAML = "application/yaml; charset=utf-8"
+
+var (
+	// YAML is a static singleton yaml result provider.
+	YAML YAMLResultProvider
+	// assert it implements result provider.
+	_ web.ResultProvider = (*YAMLResultProvider)(nil)
+)
+
+// YAMLResultProvider are context results for yaml responses.
+type YAMLResultProvider struct{}
+
+// NotFound returns a service response.
+func (yrp YAMLResultProvider) NotFound() web.Result {
+	return &YAMLResult{
+		StatusCode: http.StatusNotFound,
+		Response:   "Not Found",
+	}
+}
+
+// NotAuthorized returns a service response.
+func (yrp YAMLResultProvider) NotAuthorized() web.Result {
+	return &YAMLResult{
+		StatusCode: http.StatusForbidden,
+		Response:   "Not Authorized",
+	}
+}
+
+// InternalError returns a service response.
+func (yrp YAMLResultProvider) InternalError(err error) web.Result {
+	if err != nil {
+		return web.ResultWithLoggedError(&YAMLResult{
+			StatusCode: http.StatusInternalServerError,
+			Response:   err.Error(),
+		}, err)
+	}
+	return web.ResultWithLoggedError(&YAMLResult{
+		StatusCode: http.StatusInternalServerError,
+		Response:   "Internal Server Error",
+	}, err)
+}
+
+// BadRequest returns a service response.
+func (yrp YAMLResultProvider) BadRequest(err error) web.Result {
+	if err != nil {
+		return &YAMLResult{
+			StatusCode: http.StatusBadRequest,
+			Response:   err.Error(),
+		}
+	}
+	return &YAMLResult{
+		StatusCode: http.StatusBadRequest,
+		Response:   "Bad Request",
+	}
+}
+
+// OK returns a service response.
+func (yrp YAMLResultProvider) OK() web.Result {
+	return &YAMLResult{
+		StatusCode: http.StatusOK,
+		Response:   "OK!",
+	}
+}
+
+// Status returns a yaml result.
+func (yrp YAMLResultProvider) Status(statusCode int, response ...interface{}) web.Result {
+	return &YAMLResult{
+		StatusCode: statusCode,
+		Response:   web.ResultOrDefault(http.StatusText(statusCode), response...),
+	}
+}
+
+// Result returns a yaml response.
+func (yrp YAMLResultProvider) Result(response interface{}) web.Result {
+	return &YAMLResult{
+		StatusCode: http.StatusOK,
+		Response:   response,
+	}
+}
+
+// YAMLResult is a yaml result.
+type YAMLResult struct {
+	StatusCode int
+	Response   interface{}
+}
+
+// Render renders the result.
+func (yr *YAMLResult) Render(ctx *web.Ctx) error {
+	contents, err := yaml.Marshal(yr.Response)
+	if err != nil {
+		return ex.New(err)
+	}
+	ctx.Response.Header().Set(web.HeaderContentType, ContentTypeYAML)
+	ctx.Response.WriteHeader(yr.StatusCode)
+	_, err = ctx.Response.Write(contents)
+	return ex.New(err)
+}